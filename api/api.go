@@ -2,22 +2,40 @@
 package api
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/diamondburned/go-lovense/pattern"
 )
 
-// DefaultForm are the default form values.
-var DefaultForm = url.Values{
-	"appVersion": {"5.1.6"},
-	"version":    {"2"},
-	"platform":   {"android"},
+// DefaultForm are the default form values. Clients get their own copy of it
+// when they're created.
+var DefaultForm = NewDefaultForm("5.1.6", "2", "android")
+
+// NewDefaultForm returns new form values in the same shape as DefaultForm. It
+// can be assigned to a Client's DefaultForm to talk to an endpoint that expects
+// different values.
+func NewDefaultForm(appVersion, version, platform string) url.Values {
+	return url.Values{
+		"appVersion": {appVersion},
+		"version":    {version},
+		"platform":   {platform},
+	}
 }
 
 // DefaultHeader are the default request headers.
@@ -50,6 +68,94 @@ func WithPOSTForm(form url.Values) RequestOpt {
 	}
 }
 
+// WithJSONBody injects the given value marshaled as a JSON body. If v cannot be
+// marshaled, then the request will fail with the marshaling error once sent.
+func WithJSONBody(v interface{}) RequestOpt {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return withBodyError(fmt.Errorf("cannot marshal JSON body: %w", err))
+	}
+
+	return func(c *Client, r *http.Request) {
+		setBody(r, "application/json; charset=utf-8", encoded)
+	}
+}
+
+// WithMultipart injects the given fields and files as a multipart/form-data
+// body, along with the client's default form. The files are read fully when
+// WithMultipart is called; if that fails, then the request will fail with the
+// read error once sent.
+func WithMultipart(fields map[string]string, files map[string]io.Reader) RequestOpt {
+	fileNames := make([]string, 0, len(files))
+	fileData := make(map[string][]byte, len(files))
+
+	for name, f := range files {
+		b, err := io.ReadAll(f)
+		if err != nil {
+			return withBodyError(fmt.Errorf("cannot read file %q: %w", name, err))
+		}
+		fileNames = append(fileNames, name)
+		fileData[name] = b
+	}
+
+	sort.Strings(fileNames)
+
+	return func(c *Client, r *http.Request) {
+		form := cloneValues(c.DefaultForm)
+		for k, v := range fields {
+			form[k] = append(form[k], v)
+		}
+
+		keys := make([]string, 0, len(form))
+		for k := range form {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+
+		// Writing into a bytes.Buffer never fails, so errors are ignored.
+		for _, k := range keys {
+			for _, v := range form[k] {
+				w.WriteField(k, v)
+			}
+		}
+		for _, name := range fileNames {
+			part, _ := w.CreateFormFile(name, name)
+			part.Write(fileData[name])
+		}
+		w.Close()
+
+		setBody(r, w.FormDataContentType(), buf.Bytes())
+	}
+}
+
+func setBody(r *http.Request, contentType string, body []byte) {
+	r.Header.Set("Content-Type", contentType)
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	r.ContentLength = int64(len(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	r.Body, _ = r.GetBody()
+}
+
+// withBodyError returns a RequestOpt that makes the request fail with err once
+// it's sent.
+func withBodyError(err error) RequestOpt {
+	return func(c *Client, r *http.Request) {
+		r.GetBody = func() (io.ReadCloser, error) { return nil, err }
+		r.Body = io.NopCloser(errReader{err})
+	}
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
 // WithHeader injects the given header.
 func WithHeader(h http.Header) RequestOpt {
 	return func(c *Client, r *http.Request) {
@@ -59,7 +165,66 @@ func WithHeader(h http.Header) RequestOpt {
 	}
 }
 
-// Client is a general API client.
+// WithBearer injects the given token as a bearer token into the Authorization
+// header.
+func WithBearer(token string) RequestOpt {
+	return func(c *Client, r *http.Request) {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// WithBasicAuth injects the given username and password as HTTP basic
+// authentication into the Authorization header.
+func WithBasicAuth(user, pass string) RequestOpt {
+	return func(c *Client, r *http.Request) {
+		r.SetBasicAuth(user, pass)
+	}
+}
+
+// CheckRedirectFunc is the type of http.Client's CheckRedirect.
+type CheckRedirectFunc = func(req *http.Request, via []*http.Request) error
+
+type checkRedirectKey struct{}
+
+// WithCheckRedirect overrides the redirect policy for this request only. See
+// http.Client's CheckRedirect for how f is used; for example, returning
+// http.ErrUseLastResponse stops following redirects.
+func WithCheckRedirect(f CheckRedirectFunc) RequestOpt {
+	return func(c *Client, r *http.Request) {
+		*r = *r.WithContext(context.WithValue(r.Context(), checkRedirectKey{}, f))
+	}
+}
+
+// WithIdempotencyKey injects the given key into the Idempotency-Key header, so
+// that retrying a request with the same key doesn't perform it twice. If key is
+// empty, then a random UUID is generated once and reused for every request
+// that the option is applied to.
+func WithIdempotencyKey(key string) RequestOpt {
+	if key == "" {
+		key = newUUID()
+	}
+
+	return func(c *Client, r *http.Request) {
+		r.Header.Set("Idempotency-Key", key)
+	}
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("cannot read random bytes: " + err.Error())
+	}
+
+	b[6] = (b[6] & 0x0F) | 0x40 // version 4
+	b[8] = (b[8] & 0x3F) | 0x80 // variant 10
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// Client is a general API client. It is safe to use concurrently, but its
+// fields, including those in ClientData, must not be modified once it is in
+// use. Use WithContext or copy the Client to get one with different fields.
 type Client struct {
 	*http.Client
 	*ClientData
@@ -71,6 +236,13 @@ type ClientData struct {
 	Host          string // apps.lovense.com
 	DefaultForm   url.Values
 	DefaultHeader http.Header
+	// StrictJSON makes DoJSON error out on JSON fields that don't exist in the
+	// given value, which is useful for catching API changes. It is false by
+	// default.
+	StrictJSON bool
+	// DefaultOpts are applied to every request before the options given to
+	// each call, so the latter can override them.
+	DefaultOpts []RequestOpt
 }
 
 // NewClient returns a new client.
@@ -87,13 +259,22 @@ func NewClientContext(ctx context.Context) *Client {
 	return &Client{
 		Client: &client,
 		ClientData: &ClientData{
-			Host:        "apps.lovense.com",
-			DefaultForm: DefaultForm,
+			Host: "apps.lovense.com",
+			// Copy the form so that modifying it doesn't modify the global.
+			DefaultForm: cloneValues(DefaultForm),
 		},
 		ctx: ctx,
 	}
 }
 
+func cloneValues(v url.Values) url.Values {
+	cpy := make(url.Values, len(v))
+	for k, vs := range v {
+		cpy[k] = append([]string(nil), vs...)
+	}
+	return cpy
+}
+
 // WithContext returns a copy of Client with the given context.
 func (c *Client) WithContext(ctx context.Context) *Client {
 	cpy := *c
@@ -101,6 +282,80 @@ func (c *Client) WithContext(ctx context.Context) *Client {
 	return &cpy
 }
 
+// WithStrictJSON returns a copy of Client with StrictJSON set to strict.
+func (c *Client) WithStrictJSON(strict bool) *Client {
+	data := *c.ClientData
+	data.StrictJSON = strict
+
+	cpy := *c
+	cpy.ClientData = &data
+	return &cpy
+}
+
+// WithDryRun returns a copy of Client that records its requests instead of
+// sending them. Every request gets a 200 response with an empty successful
+// JSON body. The requests can be retrieved with RecordedRequests.
+func (c *Client) WithDryRun() *Client {
+	client := *c.Client
+	client.Transport = &recordingTransport{}
+
+	cpy := *c
+	cpy.Client = &client
+	return &cpy
+}
+
+// RecordedRequests returns the requests recorded so far by a Client returned
+// by WithDryRun, in the order they were made. Their bodies can be read again.
+// It returns nil for other clients.
+func (c *Client) RecordedRequests() []*http.Request {
+	t, ok := c.Client.Transport.(*recordingTransport)
+	if !ok {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]*http.Request(nil), t.requests...)
+}
+
+// dryRunBody is the response body of requests that aren't sent.
+const dryRunBody = `{"code":0,"result":true}`
+
+type recordingTransport struct {
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func (t *recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	recorded := r.Clone(r.Context())
+
+	if r.Body != nil {
+		body, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read request body: %w", err)
+		}
+		recorded.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	t.mu.Lock()
+	t.requests = append(t.requests, recorded)
+	t.mu.Unlock()
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(strings.NewReader(dryRunBody)),
+		ContentLength: int64(len(dryRunBody)),
+		Request:       r,
+	}, nil
+}
+
 // DoGET sends a GET to the given URL.
 func (c *Client) DoGET(path string, outJSON interface{}, opts ...RequestOpt) error {
 	return c.DoJSON("GET", path, outJSON, opts...)
@@ -120,37 +375,87 @@ func (c *Client) DoJSON(method, path string, outJSON interface{}, opts ...Reques
 	}
 	defer r.Body.Close()
 
+	body, err := decodedBody(r)
+	if err != nil {
+		return err
+	}
+
 	if r.StatusCode < 200 || r.StatusCode > 299 {
 		serverErr := ServerError{Status: r.StatusCode}
-		json.NewDecoder(r.Body).Decode(&serverErr) // error doesn't matter
+		json.NewDecoder(body).Decode(&serverErr) // error doesn't matter
 		return &serverErr
 	}
 
 	if outJSON != nil {
-		if err := json.NewDecoder(r.Body).Decode(outJSON); err != nil {
-			return fmt.Errorf("cannot decode JSON response: %w", err)
+		if err := c.decodeJSON(body, outJSON); err != nil {
+			return &DecodeError{Err: err}
 		}
 	}
 
 	return nil
 }
 
+// decodedBody returns the body of r, decompressing it if it's still
+// gzip-encoded. http.Transport only decompresses transparently if the request
+// didn't set its own Accept-Encoding, which a RequestOpt or DefaultHeader might
+// do.
+func decodedBody(r *http.Response) (io.Reader, error) {
+	if r.Uncompressed || !strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		return r.Body, nil
+	}
+
+	z, err := gzip.NewReader(r.Body)
+	if err != nil {
+		return nil, &DecodeError{Err: fmt.Errorf("cannot read gzip: %w", err)}
+	}
+
+	return z, nil
+}
+
+func (c *Client) decodeJSON(r io.Reader, v interface{}) error {
+	dec := json.NewDecoder(r)
+	if !c.StrictJSON {
+		return dec.Decode(v)
+	}
+
+	dec.DisallowUnknownFields()
+
+	body, ok := v.(*ResponseBody)
+	if !ok {
+		return dec.Decode(v)
+	}
+
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+
+	return body.unmarshalJSON(raw, true)
+}
+
 // Do sends a HTTP request and returns a typical HTTP response.
 func (c *Client) Do(method, path string, opts ...RequestOpt) (*http.Response, error) {
-	fullURL := path
-
 	// awful hack
-	if !strings.Contains(path, "://") {
-		u := url.URL{
-			Scheme: "https",
-			Host:   c.Host,
-			Path:   path,
+	if strings.Contains(path, "://") {
+		u, err := url.Parse(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse URL: %w", err)
 		}
-		fullURL = u.String()
+		return c.DoURL(method, u, opts...)
 	}
 
+	return c.DoURL(method, &url.URL{
+		Scheme: "https",
+		Host:   c.Host,
+		Path:   path,
+	}, opts...)
+}
+
+// DoURL is like Do, except it takes in an already-parsed URL, which is used
+// as-is instead of being resolved against c.Host.
+func (c *Client) DoURL(method string, u *url.URL, opts ...RequestOpt) (*http.Response, error) {
 	// TODO: string + reparse is dumb
-	r, err := http.NewRequest(method, fullURL, nil)
+	r, err := http.NewRequest(method, u.String(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create request: %w", err)
 	}
@@ -159,11 +464,23 @@ func (c *Client) Do(method, path string, opts ...RequestOpt) (*http.Response, er
 		r.Header[k] = v
 	}
 
+	for _, opt := range c.DefaultOpts {
+		opt(c, r)
+	}
+
 	for _, opt := range opts {
 		opt(c, r)
 	}
 
-	return c.Client.Do(r)
+	client := c.Client
+
+	if f, ok := r.Context().Value(checkRedirectKey{}).(CheckRedirectFunc); ok {
+		cpy := *client
+		cpy.CheckRedirect = f
+		client = &cpy
+	}
+
+	return client.Do(r)
 }
 
 // ServerError is the server error. It implements error.
@@ -187,16 +504,178 @@ func (e *ServerError) Error() string {
 	)
 }
 
+// DecodeError is returned when the response body cannot be decoded. It
+// implements error.
+type DecodeError struct {
+	Err error
+}
+
+// Error implements error.
+func (e *DecodeError) Error() string {
+	if e.Truncated() {
+		return fmt.Sprintf("cannot decode truncated JSON response: %v", e.Err)
+	}
+	return fmt.Sprintf("cannot decode JSON response: %v", e.Err)
+}
+
+// Unwrap returns the underlying decoding error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Truncated returns true if the response body ended before a complete JSON
+// value could be read, which usually means the connection was dropped. Such
+// requests are typically worth retrying, unlike ones with malformed bodies.
+func (e *DecodeError) Truncated() bool {
+	return errors.Is(e.Err, io.ErrUnexpectedEOF)
+}
+
+// Syntax returns true if the response body is malformed JSON.
+func (e *DecodeError) Syntax() bool {
+	var syntaxErr *json.SyntaxError
+	return errors.As(e.Err, &syntaxErr)
+}
+
+// ErrorKind is the broad category of an error returned by this package.
+type ErrorKind int
+
+const (
+	// UnknownErrorKind is any error that isn't one of the other kinds,
+	// including nil.
+	UnknownErrorKind ErrorKind = iota
+	// NetworkErrorKind is an error from sending the request or reading the
+	// response, such as a dropped connection. These are usually worth
+	// retrying.
+	NetworkErrorKind
+	// ServerErrorKind is a *ServerError, i.e. the server responded with a
+	// non-2xx status code.
+	ServerErrorKind
+	// ParseErrorKind is an error from decoding a JSON response or parsing a
+	// downloaded pattern file.
+	ParseErrorKind
+)
+
+// String returns the kind in lowercase words.
+func (k ErrorKind) String() string {
+	switch k {
+	case NetworkErrorKind:
+		return "network error"
+	case ServerErrorKind:
+		return "server error"
+	case ParseErrorKind:
+		return "parse error"
+	default:
+		return "unknown error"
+	}
+}
+
+// ClassifyError returns the kind of the given error, which may be wrapped. This
+// is useful for deciding whether to retry a request. A *DecodeError of a
+// truncated response is classified as a NetworkErrorKind, since it usually
+// means that the connection was dropped.
+func ClassifyError(err error) ErrorKind {
+	if err == nil {
+		return UnknownErrorKind
+	}
+
+	var serverErr *ServerError
+	if errors.As(err, &serverErr) {
+		return ServerErrorKind
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return NetworkErrorKind
+	}
+
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		if decodeErr.Truncated() {
+			return NetworkErrorKind
+		}
+		return ParseErrorKind
+	}
+
+	var numErr *strconv.NumError
+	var mismatchErr *pattern.FeatureMismatchError
+	switch {
+	case
+		errors.As(err, &numErr),
+		errors.As(err, &mismatchErr),
+		errors.Is(err, pattern.ErrEmptyPattern),
+		errors.Is(err, pattern.ErrUnknownVersion),
+		errors.Is(err, pattern.ErrTruncatedPoints),
+		errors.Is(err, pattern.ErrInvalidStride),
+		errors.Is(err, pattern.ErrMalformed):
+		return ParseErrorKind
+	}
+
+	return UnknownErrorKind
+}
+
 // ResponseBody is the general response body that the backend responds with.
 type ResponseBody struct {
 	Code    int64       `json:"code"`
 	Data    interface{} `json:"data"`
 	Message string      `json:"message"`
 	Result  bool        `json:"result"`
+
+	raw json.RawMessage
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It keeps the raw data around for
+// RawData and DecodeData. If Data is a pointer, then the data is decoded into
+// it like usual.
+func (body *ResponseBody) UnmarshalJSON(b []byte) error {
+	return body.unmarshalJSON(b, false)
 }
 
-// RawData returns Data as a raw JSON message.
+// unmarshalJSON is UnmarshalJSON, optionally rejecting unknown fields in both
+// the body and its data. This is needed since the strictness of the decoder
+// calling UnmarshalJSON doesn't carry over.
+func (body *ResponseBody) unmarshalJSON(b []byte, strict bool) error {
+	type rawBody ResponseBody
+
+	var raw struct {
+		rawBody
+		Data json.RawMessage `json:"data"`
+	}
+
+	if err := decodeJSONBytes(b, &raw, strict); err != nil {
+		return err
+	}
+
+	body.Code = raw.Code
+	body.Message = raw.Message
+	body.Result = raw.Result
+	body.raw = raw.Data
+
+	if len(raw.Data) == 0 {
+		return nil
+	}
+
+	return decodeJSONBytes(raw.Data, &body.Data, strict)
+}
+
+func decodeJSONBytes(b []byte, v interface{}, strict bool) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(v)
+}
+
+// RawData returns Data as a raw JSON message. If the body was unmarshaled, then
+// the original data is returned as-is; otherwise, Data is marshaled.
 func (body ResponseBody) RawData() json.RawMessage {
+	if body.raw != nil {
+		return body.raw
+	}
 	b, _ := json.Marshal(body.Data)
 	return b
 }
+
+// DecodeData unmarshals the raw data into v.
+func (body ResponseBody) DecodeData(v interface{}) error {
+	return json.Unmarshal(body.RawData(), v)
+}
@@ -0,0 +1,428 @@
+package api
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/diamondburned/go-lovense/pattern"
+)
+
+func TestDecodeError(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		truncated bool
+		syntax    bool
+	}{
+		{"truncated", `{"code":0,"data":[`, true, false},
+		{"syntax", `{"code":0,}`, false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, test.body)
+			})
+
+			var body ResponseBody
+			err := c.DoGET("/", &body)
+
+			decodeErr, ok := err.(*DecodeError)
+			if !ok {
+				t.Fatalf("expected *DecodeError, got %T: %v", err, err)
+			}
+			if decodeErr.Truncated() != test.truncated {
+				t.Errorf("expected Truncated() = %v", test.truncated)
+			}
+			if decodeErr.Syntax() != test.syntax {
+				t.Errorf("expected Syntax() = %v", test.syntax)
+			}
+		})
+	}
+}
+
+func TestWithJSONBody(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Errorf("unexpected Content-Type %q", ct)
+		}
+
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error("cannot read body:", err)
+		}
+
+		if string(b) != `{"id":"abc"}` {
+			t.Errorf("unexpected body %q", b)
+		}
+	})
+
+	body := struct {
+		ID string `json:"id"`
+	}{"abc"}
+
+	if err := c.DoPOST("/", nil, WithJSONBody(body)); err != nil {
+		t.Fatal("cannot POST:", err)
+	}
+
+	if err := c.DoPOST("/", nil, WithJSONBody(func() {})); err == nil {
+		t.Fatal("expected error marshaling a func")
+	}
+}
+
+func TestDoURL(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if src := r.URL.Query().Get("src"); src != "https://example.com" {
+			t.Errorf("unexpected src query %q", src)
+		}
+	})
+
+	u := &url.URL{
+		Scheme:   "https",
+		Host:     c.Host,
+		Path:     "/pattern",
+		RawQuery: url.Values{"src": {"https://example.com"}}.Encode(),
+	}
+
+	r, err := c.DoURL("GET", u)
+	if err != nil {
+		t.Fatal("cannot GET:", err)
+	}
+	r.Body.Close()
+}
+
+func TestResponseBodyDecodeData(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"code":0,"data":{"id":"abc","count":12345678901234567},"result":true}`)
+	})
+
+	var body ResponseBody
+	if err := c.DoGET("/", &body); err != nil {
+		t.Fatal("cannot GET:", err)
+	}
+
+	if !body.Result {
+		t.Error("expected result to be true")
+	}
+
+	var data struct {
+		ID    string `json:"id"`
+		Count int64  `json:"count"`
+	}
+
+	if err := body.DecodeData(&data); err != nil {
+		t.Fatal("cannot decode data:", err)
+	}
+
+	// Count would lose precision if it were re-marshaled from a float64.
+	if data.ID != "abc" || data.Count != 12345678901234567 {
+		t.Errorf("unexpected data %+v", data)
+	}
+}
+
+func TestNewDefaultForm(t *testing.T) {
+	var form url.Values
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.PostForm
+	})
+	c.DefaultForm = NewDefaultForm("6.0.0", "3", "ios")
+
+	if err := c.DoPOST("/", nil, WithPOSTForm(nil)); err != nil {
+		t.Fatal("cannot POST:", err)
+	}
+
+	for k, v := range map[string]string{"appVersion": "6.0.0", "version": "3", "platform": "ios"} {
+		if got := form.Get(k); got != v {
+			t.Errorf("expected %s %q, got %q", k, v, got)
+		}
+	}
+
+	if v := DefaultForm.Get("platform"); v != "android" {
+		t.Errorf("DefaultForm was modified, platform is %q", v)
+	}
+}
+
+func TestDefaultOpts(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if v := r.Header.Get("X-Request-ID"); v != r.URL.Query().Get("expect") {
+			t.Errorf("unexpected X-Request-ID %q", v)
+		}
+	})
+	c.DefaultOpts = []RequestOpt{
+		WithHeader(http.Header{"X-Request-ID": {"default"}}),
+	}
+
+	for _, test := range []struct {
+		expect string
+		opts   []RequestOpt
+	}{
+		{"default", nil},
+		{"override", []RequestOpt{WithHeader(http.Header{"X-Request-ID": {"override"}})}},
+	} {
+		u := &url.URL{
+			Scheme:   "https",
+			Host:     c.Host,
+			RawQuery: url.Values{"expect": {test.expect}}.Encode(),
+		}
+
+		r, err := c.DoURL("GET", u, test.opts...)
+		if err != nil {
+			t.Fatal("cannot GET:", err)
+		}
+		r.Body.Close()
+	}
+}
+
+func TestWithMultipart(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Error("cannot parse multipart form:", err)
+			return
+		}
+
+		if v := r.FormValue("name"); v != "test" {
+			t.Errorf("unexpected name %q", v)
+		}
+		if v := r.FormValue("platform"); v != "android" {
+			t.Errorf("expected default form to be included, got platform %q", v)
+		}
+
+		f, _, err := r.FormFile("pattern")
+		if err != nil {
+			t.Error("cannot get file:", err)
+			return
+		}
+		defer f.Close()
+
+		b, _ := io.ReadAll(f)
+		if string(b) != "V:1;#0;" {
+			t.Errorf("unexpected file %q", b)
+		}
+	})
+
+	opt := WithMultipart(
+		map[string]string{"name": "test"},
+		map[string]io.Reader{"pattern": strings.NewReader("V:1;#0;")},
+	)
+
+	if err := c.DoPOST("/", nil, opt); err != nil {
+		t.Fatal("cannot POST:", err)
+	}
+
+	opt = WithMultipart(nil, map[string]io.Reader{
+		"pattern": iotest.ErrReader(errors.New("oops")),
+	})
+
+	if err := c.DoPOST("/", nil, opt); err == nil {
+		t.Fatal("expected error reading file")
+	}
+}
+
+func TestStrictJSON(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"code":0,"data":[{"id":"a","new":1}],"result":true}`)
+	})
+
+	var patterns []Pattern
+
+	body := ResponseBody{Data: &patterns}
+	if err := c.DoGET("/", &body); err != nil {
+		t.Fatal("unexpected error decoding leniently:", err)
+	}
+	if len(patterns) != 1 || patterns[0].ID != "a" {
+		t.Fatalf("unexpected patterns %+v", patterns)
+	}
+
+	strict := c.WithStrictJSON(true)
+	if c.StrictJSON {
+		t.Fatal("WithStrictJSON modified the original client")
+	}
+
+	body = ResponseBody{Data: &patterns}
+	if err := strict.DoGET("/", &body); err == nil {
+		t.Fatal("expected error decoding unknown field strictly")
+	}
+}
+
+func TestWithIdempotencyKey(t *testing.T) {
+	var keys []string
+
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+	})
+
+	opt := WithIdempotencyKey("")
+	for i := 0; i < 2; i++ {
+		if err := c.DoPOST("/", nil, opt); err != nil {
+			t.Fatal("cannot POST:", err)
+		}
+	}
+
+	if err := c.DoPOST("/", nil, WithIdempotencyKey("abc")); err != nil {
+		t.Fatal("cannot POST:", err)
+	}
+
+	if len(keys[0]) != 36 || keys[0] != keys[1] {
+		t.Errorf("expected the same generated UUID to be reused, got %q", keys[:2])
+	}
+	if keys[2] != "abc" {
+		t.Errorf("expected given key, got %q", keys[2])
+	}
+}
+
+func TestGzipResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+	}{
+		{"transport", nil},
+		{"explicit", http.Header{"Accept-Encoding": {"gzip"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "gzip")
+				z := gzip.NewWriter(w)
+				io.WriteString(z, `{"code":0,"data":"hello"}`)
+				z.Close()
+			})
+
+			var body ResponseBody
+			if err := c.DoGET("/", &body, WithHeader(test.header)); err != nil {
+				t.Fatal("cannot GET:", err)
+			}
+
+			var data string
+			if err := body.DecodeData(&data); err != nil {
+				t.Fatal("cannot decode data:", err)
+			}
+
+			if data != "hello" {
+				t.Errorf("expected data %q, got %q", "hello", data)
+			}
+		})
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	tests := []struct {
+		name   string
+		opt    RequestOpt
+		expect string
+	}{
+		{"bearer", WithBearer("token"), "Bearer token"},
+		{"basic", WithBasicAuth("user", "pass"), "Basic dXNlcjpwYXNz"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if auth := r.Header.Get("Authorization"); auth != test.expect {
+					t.Errorf("expected Authorization %q, got %q", test.expect, auth)
+				}
+			})
+
+			r, err := c.Do("GET", "/", test.opt)
+			if err != nil {
+				t.Fatal("cannot GET:", err)
+			}
+			r.Body.Close()
+		})
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		expect ErrorKind
+	}{
+		{"nil", nil, UnknownErrorKind},
+		{"other", errors.New("other"), UnknownErrorKind},
+		{"server", fmt.Errorf("cannot find: %w", &ServerError{Status: 500}), ServerErrorKind},
+		{"network", &url.Error{Op: "Get", URL: "/", Err: io.EOF}, NetworkErrorKind},
+		{"truncated", &DecodeError{Err: io.ErrUnexpectedEOF}, NetworkErrorKind},
+		{"decode", &DecodeError{Err: &json.SyntaxError{}}, ParseErrorKind},
+		{"pattern", fmt.Errorf("cannot read header: %w", pattern.ErrEmptyPattern), ParseErrorKind},
+		{"mismatch", &pattern.FeatureMismatchError{}, ParseErrorKind},
+		{"bad version", parseErr("V:x;F:v;#0;"), ParseErrorKind},
+		{"bad interval", parseErr("V:1;F:v;S:x;#0;"), ParseErrorKind},
+		{"interval range", parseErr("V:1;F:v;S:0;#0;"), ParseErrorKind},
+		{"no delimiter", parseErr("V:1;F:v;S:100;1;2;"), ParseErrorKind},
+		{"short point", parseErr("V:1;F:v1,v2;#0,1;2;3,4;"), ParseErrorKind},
+		{"long point", parseErr("V:1;F:v1,v2;#0,1;2,3,4;"), ParseErrorKind},
+	}
+
+	for _, test := range tests {
+		if kind := ClassifyError(test.err); kind != test.expect {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expect, kind)
+		}
+	}
+}
+
+// parseErr returns the error from parsing the given pattern file.
+func parseErr(s string) error {
+	_, err := pattern.ParseString(s)
+	return err
+}
+
+func TestWithDryRun(t *testing.T) {
+	c := NewClient().WithDryRun()
+
+	var body ResponseBody
+	if err := c.DoPOST("/wear/pattern/v2/find", &body, WithPOSTForm(url.Values{"page": {"1"}})); err != nil {
+		t.Fatal("cannot POST:", err)
+	}
+	if !body.Result {
+		t.Error("expected a successful response")
+	}
+
+	requests := c.RecordedRequests()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 recorded request, got %d", len(requests))
+	}
+
+	r := requests[0]
+	if r.URL.Path != "/wear/pattern/v2/find" {
+		t.Errorf("unexpected path %q", r.URL.Path)
+	}
+	if err := r.ParseForm(); err != nil {
+		t.Fatal("cannot parse recorded form:", err)
+	}
+	if v := r.PostForm.Get("page"); v != "1" {
+		t.Errorf("expected page 1, got %q", v)
+	}
+
+	if requests := NewClient().RecordedRequests(); requests != nil {
+		t.Errorf("expected no recorded requests, got %d", len(requests))
+	}
+}
+
+// newTestClient returns a Client that sends all its requests to a local test
+// server serving h.
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	srv := httptest.NewTLSServer(h)
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal("cannot parse test server URL:", err)
+	}
+
+	c := NewClient()
+	c.Client = srv.Client()
+	c.Host = u.Host
+
+	return c
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+
+	"github.com/diamondburned/go-lovense/pattern"
+)
+
+// patternCache is a concurrency-safe LRU cache of parsed patterns.
+type patternCache struct {
+	mu    sync.Mutex
+	list  *list.List // of *patternCacheEntry, most recently used first
+	items map[string]*list.Element
+	size  int
+}
+
+type patternCacheEntry struct {
+	key     string
+	pattern *pattern.Pattern
+}
+
+func newPatternCache(size int) *patternCache {
+	return &patternCache{
+		list:  list.New(),
+		items: make(map[string]*list.Element, size),
+		size:  size,
+	}
+}
+
+func (c *patternCache) get(key string) (*pattern.Pattern, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	c.list.MoveToFront(elem)
+	return elem.Value.(*patternCacheEntry).pattern, true
+}
+
+func (c *patternCache) add(key string, p *pattern.Pattern) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		elem.Value.(*patternCacheEntry).pattern = p
+		c.list.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.list.PushFront(&patternCacheEntry{key, p})
+
+	if c.list.Len() > c.size {
+		oldest := c.list.Back()
+		c.list.Remove(oldest)
+		delete(c.items, oldest.Value.(*patternCacheEntry).key)
+	}
+}
+
+func (c *patternCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.list.Remove(elem)
+		delete(c.items, key)
+	}
+}
+
+// clear removes all patterns and returns their keys.
+func (c *patternCache) clear() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := make([]string, 0, len(c.items))
+	for key := range c.items {
+		keys = append(keys, key)
+	}
+
+	c.list.Init()
+	c.items = make(map[string]*list.Element, c.size)
+
+	return keys
+}
+
+// ErrNotModified is returned by DownloadPattern if the server says that the
+// pattern hasn't changed since it was last downloaded. The cached pattern is
+// returned along with it if there is one.
+var ErrNotModified = errors.New("pattern not modified")
+
+// Validators are the response headers used for conditional requests.
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// ValidatorStore stores the Validators of downloaded patterns, so that
+// downloading them again can be done conditionally. Implementations must be
+// safe for concurrent use.
+type ValidatorStore interface {
+	// LoadValidators returns the validators for the given key, or false if
+	// there are none.
+	LoadValidators(key string) (Validators, bool)
+	// StoreValidators stores the validators for the given key.
+	StoreValidators(key string, v Validators)
+	// DeleteValidators removes the validators for the given key, if any.
+	DeleteValidators(key string)
+}
+
+// MemoryValidatorStore is a ValidatorStore that keeps validators in memory. The
+// zero value is ready to use.
+type MemoryValidatorStore struct {
+	m sync.Map // string -> Validators
+}
+
+var _ ValidatorStore = (*MemoryValidatorStore)(nil)
+
+// LoadValidators implements ValidatorStore.
+func (s *MemoryValidatorStore) LoadValidators(key string) (Validators, bool) {
+	v, ok := s.m.Load(key)
+	if !ok {
+		return Validators{}, false
+	}
+	return v.(Validators), true
+}
+
+// StoreValidators implements ValidatorStore.
+func (s *MemoryValidatorStore) StoreValidators(key string, v Validators) {
+	s.m.Store(key, v)
+}
+
+// DeleteValidators implements ValidatorStore.
+func (s *MemoryValidatorStore) DeleteValidators(key string) {
+	s.m.Delete(key)
+}
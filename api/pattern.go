@@ -2,9 +2,15 @@ package api
 
 import (
 	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/diamondburned/go-lovense/pattern"
 )
@@ -12,11 +18,90 @@ import (
 // PatternClient handles pattern-fetching routes.
 type PatternClient struct {
 	*Client
+
+	// DefaultPageSize is the page size used by Find if its pageSize is 0. If
+	// this is also 0, then 15 is used.
+	DefaultPageSize int
+	// DefaultFindType is the type used by Find if its typ is empty.
+	DefaultFindType PatternFindType
+
+	pageMu sync.Mutex
+	page   int
+
+	cache      *patternCache
+	validators ValidatorStore
+}
+
+// PatternClientOpt is the type for a PatternClient option.
+type PatternClientOpt func(*PatternClient)
+
+// WithPatternCache makes DownloadPattern keep up to size of the most recently
+// downloaded patterns in memory, so downloading them again doesn't hit the
+// network. Cached patterns are shared, so they must not be modified.
+func WithPatternCache(size int) PatternClientOpt {
+	return func(c *PatternClient) {
+		if size > 0 {
+			c.cache = newPatternCache(size)
+		}
+	}
+}
+
+// InvalidateCache removes the downloaded pattern with the given ID from the
+// cache, so it is downloaded again next time. Patterns without an ID are cached
+// by their CDNPath instead. Its validators are also removed from the
+// ValidatorStore, so the next download isn't conditional. It does nothing if
+// there's no cache.
+func (c *PatternClient) InvalidateCache(id string) {
+	if c.cache == nil {
+		return
+	}
+
+	c.cache.remove(id)
+
+	if c.validators != nil {
+		c.validators.DeleteValidators(id)
+	}
+}
+
+// ClearCache removes all downloaded patterns from the cache, along with their
+// validators like InvalidateCache. It does nothing if there's no cache.
+func (c *PatternClient) ClearCache() {
+	if c.cache == nil {
+		return
+	}
+
+	keys := c.cache.clear()
+
+	if c.validators != nil {
+		for _, key := range keys {
+			c.validators.DeleteValidators(key)
+		}
+	}
+}
+
+// WithValidatorStore makes DownloadPattern send conditional requests using the
+// ETag and Last-Modified headers of previous downloads, which are kept in the
+// given store. If the pattern hasn't changed, then DownloadPattern returns
+// ErrNotModified. Patterns cached with WithPatternCache are revalidated
+// instead of being used as-is.
+func WithValidatorStore(s ValidatorStore) PatternClientOpt {
+	return func(c *PatternClient) {
+		c.validators = s
+	}
 }
 
 // NewPatternClient returns a new PatternClient from the given Client.
-func NewPatternClient(c *Client) *PatternClient {
-	return &PatternClient{c}
+func NewPatternClient(c *Client, opts ...PatternClientOpt) *PatternClient {
+	client := &PatternClient{
+		Client:          c,
+		DefaultFindType: FindRecommendedPatterns,
+	}
+
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	return client
 }
 
 // Pattern describes a pattern.
@@ -40,7 +125,7 @@ type Pattern struct {
 	Self           bool        `json:"self"`
 	Status         string      `json:"status"`
 	Text           string      `json:"text"`
-	Timer          string      `json:"timer"`
+	Timer          string      `json:"timer"` // unknown, see TimerSeconds
 	ToyTag         string      `json:"toyTag"`
 	Updated        string      `json:"updated"` // YYYY/MM/DD HH:MM
 	Version        string      `json:"version"`
@@ -64,16 +149,83 @@ func (p *Pattern) AuthorOrAnon() string {
 	return "Anonymous"
 }
 
-// Features reads p.ToyTag and parses them into a list of features.
+// Features reads p.ToyTag and parses them into a list of features. Empty
+// entries are skipped, so an empty ToyTag has no features.
 func (p *Pattern) Features() []pattern.Feature {
-	t := strings.Split(p.ToyTag, ",")
-	f := make([]pattern.Feature, len(t))
-	for i, t := range t {
-		f[i] = pattern.Feature(t)
+	var f []pattern.Feature
+	for _, t := range strings.Split(p.ToyTag, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			f = append(f, pattern.Feature(t))
+		}
 	}
 	return f
 }
 
+// MotorCount returns the number of motors that the pattern uses according to
+// p.ToyTag. This is known before the pattern is downloaded.
+func (p *Pattern) MotorCount() int {
+	return len(p.Features())
+}
+
+// TimerSeconds parses p.Timer as a number. ok is false if it's empty or not a
+// number. What the timer is for is unknown; it's assumed to be in seconds, but
+// this hasn't been confirmed.
+func (p *Pattern) TimerSeconds() (secs int, ok bool) {
+	secs, err := strconv.Atoi(strings.TrimSpace(p.Timer))
+	if err != nil {
+		return 0, false
+	}
+	return secs, true
+}
+
+// MatchesFeatures returns true if the features declared in the downloaded
+// pattern's header are the same as the ones in p.ToyTag, in the same order.
+// This catches CDN files that don't match their advertised metadata.
+func (p *Pattern) MatchesFeatures(data *pattern.Pattern) bool {
+	return featuresEqual(p.Features(), data.Features)
+}
+
+// CanLayer returns true if a and b can be played at the same time on a device
+// with the given features, according to their ToyTags. This is the case if
+// their features are disjoint and both are within the device's features.
+// Patterns without any features in their ToyTag can't be layered.
+func CanLayer(device []pattern.Feature, a, b *Pattern) bool {
+	available := make(map[pattern.Feature]bool, len(device))
+	for _, f := range device {
+		available[f] = true
+	}
+
+	for _, p := range []*Pattern{a, b} {
+		features := p.Features()
+		if len(features) == 0 {
+			return false
+		}
+
+		for _, f := range features {
+			if !available[f] {
+				return false // not on the device, or already used by a
+			}
+			available[f] = false
+		}
+	}
+
+	return true
+}
+
+func featuresEqual(a, b []pattern.Feature) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
 // PatternFindType
 type PatternFindType string
 
@@ -84,11 +236,31 @@ const (
 	FindPickPatterns        PatternFindType = "pick"
 )
 
+// AllFindTypes contains all known PatternFindType values.
+var AllFindTypes = []PatternFindType{
+	FindRecommendedPatterns,
+	FindPopularPatterns,
+	FindRecentPatterns,
+	FindPickPatterns,
+}
+
+// Valid returns true if t is one of the known find types. The server returns
+// no patterns for unknown types.
+func (t PatternFindType) Valid() bool {
+	for _, known := range AllFindTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
 // Find calls the /find endpoint, which lists patterns according to the given
 // parameters.
 //
-// typ should typically be "Recommended".
-// If pageSize is 0, then 15 is used by default.
+// typ should typically be "Recommended". If typ is empty, then
+// c.DefaultFindType is used. An error is returned if typ isn't valid.
+// If pageSize is 0, then c.DefaultPageSize or 15 is used by default.
 // If page is 0, then 1 is used for the first page.
 // There is currently no known page/pageSize.
 func (c *PatternClient) Find(page, pageSize int, typ PatternFindType) ([]Pattern, error) {
@@ -98,10 +270,22 @@ func (c *PatternClient) Find(page, pageSize int, typ PatternFindType) ([]Pattern
 		page = 1
 	}
 
+	if pageSize == 0 {
+		pageSize = c.DefaultPageSize
+	}
+
 	if pageSize == 0 {
 		pageSize = 15
 	}
 
+	if typ == "" {
+		typ = c.DefaultFindType
+	}
+
+	if !typ.Valid() {
+		return nil, fmt.Errorf("unknown find type %q", typ)
+	}
+
 	res := ResponseBody{Data: &patterns}
 	err := c.DoPOST("/wear/pattern/v2/find", &res, WithPOSTForm(url.Values{
 		"pageSize": {strconv.Itoa(pageSize)},
@@ -112,7 +296,50 @@ func (c *PatternClient) Find(page, pageSize int, typ PatternFindType) ([]Pattern
 	return patterns, err
 }
 
+// FindByToy calls Find with c.DefaultFindType and returns only the patterns
+// whose ToyTag declares the same features as toyTag, in the same order, e.g.
+// "v1,v2". The server isn't known to support filtering by toy, so the
+// filtering is done on each page after it's fetched; pages may therefore have
+// fewer than pageSize patterns, or none at all.
+func (c *PatternClient) FindByToy(toyTag string, page, pageSize int) ([]Pattern, error) {
+	patterns, err := c.Find(page, pageSize, "")
+	if err != nil {
+		return nil, err
+	}
+
+	want := (&Pattern{ToyTag: toyTag}).Features()
+
+	filtered := patterns[:0]
+	for _, p := range patterns {
+		if featuresEqual(p.Features(), want) {
+			filtered = append(filtered, p)
+		}
+	}
+
+	return filtered, nil
+}
+
+// FindNext calls Find with the page after the one returned by the last
+// FindNext call, starting from the first page. The defaults are used for the
+// page size and type. Concurrent calls are serialized, so each one gets its
+// own page.
+func (c *PatternClient) FindNext() ([]Pattern, error) {
+	c.pageMu.Lock()
+	defer c.pageMu.Unlock()
+
+	patterns, err := c.Find(c.page+1, 0, "")
+	if err != nil {
+		return patterns, err
+	}
+
+	c.page++
+	return patterns, nil
+}
+
 // SearchTitle searches for patterns with the given keyword in its title.
+//
+// The response envelope only has the fields in ResponseBody, none of which are
+// a total count, so the number of results can't be known ahead of time.
 func (c *PatternClient) SearchTitle(keyword string) ([]Pattern, error) {
 	var patterns []Pattern
 
@@ -125,6 +352,7 @@ func (c *PatternClient) SearchTitle(keyword string) ([]Pattern, error) {
 }
 
 // SearchAuthor searches for patterns with the given keyword in its author field.
+// Like SearchTitle, the number of results can't be known ahead of time.
 func (c *PatternClient) SearchAuthor(keyword string) ([]Pattern, error) {
 	var patterns []Pattern
 
@@ -136,14 +364,202 @@ func (c *PatternClient) SearchAuthor(keyword string) ([]Pattern, error) {
 	return patterns, err
 }
 
+// SearchAll calls SearchTitle and SearchAuthor concurrently and merges their
+// results, deduplicated by ID. Title matches come first, followed by author
+// matches that didn't match by title, both in the order that the server
+// returned them.
+func (c *PatternClient) SearchAll(keyword string) ([]Pattern, error) {
+	var byTitle, byAuthor []Pattern
+	var titleErr, authorErr error
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		byTitle, titleErr = c.SearchTitle(keyword)
+	}()
+
+	go func() {
+		defer wg.Done()
+		byAuthor, authorErr = c.SearchAuthor(keyword)
+	}()
+
+	wg.Wait()
+
+	if titleErr != nil {
+		return nil, fmt.Errorf("cannot search title: %w", titleErr)
+	}
+	if authorErr != nil {
+		return nil, fmt.Errorf("cannot search author: %w", authorErr)
+	}
+
+	patterns := make([]Pattern, 0, len(byTitle)+len(byAuthor))
+	seen := make(map[string]struct{}, cap(patterns))
+
+	for _, results := range [][]Pattern{byTitle, byAuthor} {
+		for _, p := range results {
+			if _, ok := seen[p.ID]; ok {
+				continue
+			}
+			seen[p.ID] = struct{}{}
+			patterns = append(patterns, p)
+		}
+	}
+
+	return patterns, nil
+}
+
 // DownloadPattern downloads the given pattern from the CDN and parses it into
-// the pattern data.
-func (c *PatternClient) DownloadPattern(p *Pattern) (*pattern.Pattern, error) {
-	r, err := c.Do("GET", p.CDNPath)
+// the pattern data. opts are applied to the download request, e.g.
+// WithCheckRedirect to restrict where the pattern may be downloaded from.
+//
+// Some CDN entries respond with the pattern inside JSON instead of as a raw
+// file. If the response's Content-Type is JSON, the body is decoded as a
+// ResponseBody whose data is a string containing the pattern file, which may be
+// base64-encoded; see pattern.ParseAny. Otherwise, the body is parsed as a raw
+// pattern file.
+func (c *PatternClient) DownloadPattern(p *Pattern, opts ...RequestOpt) (*pattern.Pattern, error) {
+	return c.DownloadPatternProgress(p, nil, opts...)
+}
+
+// DownloadPatternProgress is like DownloadPattern, except onProgress is called
+// every time more of the pattern is downloaded. read is the number of bytes
+// read so far, and total is the Content-Length, or -1 if it's unknown.
+// onProgress isn't called if the pattern is cached.
+func (c *PatternClient) DownloadPatternProgress(p *Pattern, onProgress func(read, total int64), opts ...RequestOpt) (*pattern.Pattern, error) {
+	if c.cache == nil && c.validators == nil {
+		downloaded, _, err := c.downloadPattern(p, onProgress, opts)
+		return downloaded, err
+	}
+
+	key := p.ID
+	if key == "" {
+		key = p.CDNPath
+	}
+
+	var cached *pattern.Pattern
+	if c.cache != nil {
+		cached, _ = c.cache.get(key)
+	}
+
+	if c.validators == nil {
+		// Without validators, cached patterns can't be revalidated, so they're
+		// always used as-is.
+		if cached != nil {
+			return cached, nil
+		}
+	} else if c.cache == nil || cached != nil {
+		// With a cache, only revalidate what's still cached, since there'd be
+		// nothing to return if the pattern isn't modified.
+		if v, ok := c.validators.LoadValidators(key); ok {
+			opts = append([]RequestOpt{withValidators(v)}, opts...)
+		}
+	}
+
+	downloaded, header, err := c.downloadPattern(p, onProgress, opts)
 	if err != nil {
+		if errors.Is(err, ErrNotModified) {
+			return cached, err
+		}
 		return nil, err
 	}
+
+	if c.validators != nil {
+		c.validators.StoreValidators(key, Validators{
+			ETag:         header.Get("ETag"),
+			LastModified: header.Get("Last-Modified"),
+		})
+	}
+
+	if c.cache != nil {
+		c.cache.add(key, downloaded)
+	}
+
+	return downloaded, nil
+}
+
+func withValidators(v Validators) RequestOpt {
+	return func(c *Client, r *http.Request) {
+		if v.ETag != "" {
+			r.Header.Set("If-None-Match", v.ETag)
+		}
+		if v.LastModified != "" {
+			r.Header.Set("If-Modified-Since", v.LastModified)
+		}
+	}
+}
+
+func (c *PatternClient) downloadPattern(p *Pattern, onProgress func(read, total int64), opts []RequestOpt) (*pattern.Pattern, http.Header, error) {
+	u, err := url.Parse(p.CDNPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot parse CDN path: %w", err)
+	}
+
+	if !u.IsAbs() {
+		// Resolve relative paths against c.Host like Do, but keep the query.
+		u.Scheme = "https"
+		u.Host = c.Host
+	}
+
+	r, err := c.DoURL("GET", u, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
 	defer r.Body.Close()
 
-	return pattern.Parse(r.Body)
+	if r.StatusCode == http.StatusNotModified {
+		return nil, r.Header, ErrNotModified
+	}
+
+	var body io.Reader = r.Body
+	if onProgress != nil {
+		body = &progressReader{
+			r:     r.Body,
+			total: r.ContentLength,
+			f:     onProgress,
+		}
+	}
+
+	if isJSON(r.Header.Get("Content-Type")) {
+		downloaded, err := c.parseJSONPattern(body)
+		return downloaded, r.Header, err
+	}
+
+	downloaded, err := pattern.Parse(body)
+	return downloaded, r.Header, err
+}
+
+func isJSON(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (t == "application/json" || strings.HasSuffix(t, "+json"))
+}
+
+// parseJSONPattern parses a pattern file that is wrapped in a JSON response
+// body as a string.
+func (c *PatternClient) parseJSONPattern(r io.Reader) (*pattern.Pattern, error) {
+	var data string
+
+	res := ResponseBody{Data: &data}
+	if err := c.decodeJSON(r, &res); err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+
+	return pattern.ParseAny(strings.NewReader(data))
+}
+
+type progressReader struct {
+	r     io.Reader
+	read  int64
+	total int64
+	f     func(read, total int64)
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	if n > 0 {
+		r.read += int64(n)
+		r.f(r.read, r.total)
+	}
+	return n, err
 }
@@ -1,6 +1,17 @@
 package api
 
-import "testing"
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/diamondburned/go-lovense/pattern"
+	"github.com/go-test/deep"
+)
 
 func TestPatternClient(t *testing.T) {
 	c := NewPatternClient(NewClient())
@@ -29,6 +40,470 @@ func TestPatternClient(t *testing.T) {
 	})
 }
 
+func TestPatternClientFindNext(t *testing.T) {
+	var page int
+
+	c := NewPatternClient(newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		page++
+
+		if v := r.FormValue("page"); v != strconv.Itoa(page) {
+			t.Errorf("expected page %d, got %q", page, v)
+		}
+		if v := r.FormValue("pageSize"); v != "30" {
+			t.Errorf("expected page size 30, got %q", v)
+		}
+		if v := r.FormValue("type"); v != string(FindPopularPatterns) {
+			t.Errorf("expected type %q, got %q", FindPopularPatterns, v)
+		}
+
+		io.WriteString(w, `{"code":0,"data":[],"result":true}`)
+	}))
+	c.DefaultPageSize = 30
+	c.DefaultFindType = FindPopularPatterns
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.FindNext(); err != nil {
+			t.Fatal("cannot find next page:", err)
+		}
+	}
+
+	if page != 2 {
+		t.Fatalf("expected 2 requests, got %d", page)
+	}
+}
+
+func TestPatternClientFindByToy(t *testing.T) {
+	c := NewPatternClient(newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"code":0,"data":[
+			{"id":"1","toyTag":"v1,v2"},
+			{"id":"2","toyTag":"v"},
+			{"id":"3","toyTag":"v2,v1"},
+			{"id":"4","toyTag":"v1,v2"}
+		],"result":true}`)
+	}))
+
+	patterns, err := c.FindByToy("v1,v2", 1, 0)
+	if err != nil {
+		t.Fatal("cannot find patterns:", err)
+	}
+
+	var ids []string
+	for _, p := range patterns {
+		ids = append(ids, p.ID)
+	}
+
+	if diff := deep.Equal(ids, []string{"1", "4"}); diff != nil {
+		t.Fatalf("unexpected patterns: %s", diff)
+	}
+}
+
+func TestPatternClientSearchAll(t *testing.T) {
+	c := NewPatternClient(newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if v := r.FormValue("keyword"); v != "edge" {
+			t.Errorf("expected keyword %q, got %q", "edge", v)
+		}
+
+		switch r.URL.Path {
+		case "/wear/pattern/search_title":
+			io.WriteString(w, `{"code":0,"data":[{"id":"1"},{"id":"2"}],"result":true}`)
+		case "/wear/pattern/search_author":
+			io.WriteString(w, `{"code":0,"data":[{"id":"3"},{"id":"1"}],"result":true}`)
+		default:
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+	}))
+
+	patterns, err := c.SearchAll("edge")
+	if err != nil {
+		t.Fatal("cannot search:", err)
+	}
+
+	var ids []string
+	for _, p := range patterns {
+		ids = append(ids, p.ID)
+	}
+
+	if diff := deep.Equal(ids, []string{"1", "2", "3"}); diff != nil {
+		t.Fatalf("unexpected patterns: %s", diff)
+	}
+}
+
+func TestPatternClientDownloadPatternProgress(t *testing.T) {
+	const body = "V:1;T:Edge;F:v1,v2;S:100;#0,1;1,0;20,20;"
+
+	c := NewPatternClient(newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+
+	var read, total int64
+
+	p, err := c.DownloadPatternProgress(&Pattern{CDNPath: "/pattern"}, func(r, t int64) {
+		read, total = r, t
+	})
+	if err != nil {
+		t.Fatal("cannot download pattern:", err)
+	}
+
+	if len(p.Points) != 3 {
+		t.Errorf("expected 3 points, got %d", len(p.Points))
+	}
+
+	if read != int64(len(body)) || total != int64(len(body)) {
+		t.Errorf("expected progress %[1]d/%[1]d, got %d/%d", len(body), read, total)
+	}
+}
+
+func TestPatternClientDownloadPatternQuery(t *testing.T) {
+	c := NewPatternClient(newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pattern" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		if v := r.URL.Query().Get("from"); v != "https://example.com" {
+			t.Errorf("unexpected query %q", r.URL.RawQuery)
+		}
+		io.WriteString(w, "V:1;F:v;#0;1;")
+	}))
+
+	if _, err := c.DownloadPattern(&Pattern{CDNPath: "/pattern?from=https://example.com"}); err != nil {
+		t.Fatal("cannot download pattern:", err)
+	}
+}
+
+func TestPatternClientDownloadPatternJSON(t *testing.T) {
+	const raw = "V:1;T:Edge;F:v1,v2;S:100;#0,1;1,0;20,20;"
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"raw", "text/plain", raw},
+		{"json", "application/json; charset=utf-8", `{"code":0,"data":"` + raw + `"}`},
+		{"json base64", "application/json", `{"code":0,"data":"` + base64.StdEncoding.EncodeToString([]byte(raw)) + `"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := NewPatternClient(newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", test.contentType)
+				io.WriteString(w, test.body)
+			}))
+
+			p, err := c.DownloadPattern(&Pattern{CDNPath: "/pattern"})
+			if err != nil {
+				t.Fatal("cannot download pattern:", err)
+			}
+
+			if diff := deep.Equal(p.Points, pattern.Points{{0, 1}, {1, 0}, {20, 20}}); diff != nil {
+				t.Fatalf("unexpected points: %s", diff)
+			}
+		})
+	}
+}
+
+func TestPatternClientCache(t *testing.T) {
+	var requests int
+
+	c := NewPatternClient(newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		io.WriteString(w, "V:1;F:v;#0;1;2;")
+	}), WithPatternCache(1))
+
+	download := func(id string) {
+		t.Helper()
+		if _, err := c.DownloadPattern(&Pattern{ID: id, CDNPath: "/" + id}); err != nil {
+			t.Fatal("cannot download pattern:", err)
+		}
+	}
+
+	download("a")
+	download("a")
+	if requests != 1 {
+		t.Fatalf("expected 1 request after cache hit, got %d", requests)
+	}
+
+	// Evicts a, since the cache only fits 1.
+	download("b")
+	download("a")
+	if requests != 3 {
+		t.Fatalf("expected 3 requests after eviction, got %d", requests)
+	}
+
+	c.InvalidateCache("a")
+	download("a")
+	if requests != 4 {
+		t.Fatalf("expected 4 requests after invalidation, got %d", requests)
+	}
+
+	c.ClearCache()
+	download("a")
+	if requests != 5 {
+		t.Fatalf("expected 5 requests after clearing, got %d", requests)
+	}
+}
+
+func TestPatternClientConcurrent(t *testing.T) {
+	c := NewPatternClient(newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"code":0,"data":[],"result":true}`)
+	}))
+
+	var wg sync.WaitGroup
+
+	for i := 1; i <= 8; i++ {
+		wg.Add(2)
+
+		go func(page int) {
+			defer wg.Done()
+			if _, err := c.Find(page, 0, ""); err != nil {
+				t.Error("cannot find:", err)
+			}
+		}(i)
+
+		go func() {
+			defer wg.Done()
+			if _, err := c.FindNext(); err != nil {
+				t.Error("cannot find next:", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if c.page != 8 {
+		t.Errorf("expected FindNext to reach page 8, got %d", c.page)
+	}
+}
+
+func TestPatternClientDownloadCheckRedirect(t *testing.T) {
+	c := NewPatternClient(newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/pattern" {
+			http.Redirect(w, r, "/signed", http.StatusFound)
+			return
+		}
+		io.WriteString(w, "V:1;F:v;#0;1;2;")
+	}))
+
+	if _, err := c.DownloadPattern(&Pattern{CDNPath: "/pattern"}); err != nil {
+		t.Fatal("cannot download redirected pattern:", err)
+	}
+
+	var redirectedTo string
+
+	_, err := c.DownloadPattern(
+		&Pattern{CDNPath: "/pattern"},
+		WithCheckRedirect(func(r *http.Request, via []*http.Request) error {
+			redirectedTo = r.URL.Path
+			return errors.New("redirect denied")
+		}),
+	)
+	if err == nil {
+		t.Fatal("expected redirect to be denied")
+	}
+
+	if redirectedTo != "/signed" {
+		t.Errorf("expected redirect to /signed, got %q", redirectedTo)
+	}
+}
+
+func TestPatternMotorCount(t *testing.T) {
+	tests := []struct {
+		toyTag string
+		expect int
+	}{
+		{"v1,v2", 2},
+		{"v", 1},
+		{"v, r,", 2},
+		{"", 0},
+	}
+
+	for _, test := range tests {
+		meta := &Pattern{ToyTag: test.toyTag}
+		if count := meta.MotorCount(); count != test.expect {
+			t.Errorf("ToyTag %q: expected %d, got %d", test.toyTag, test.expect, count)
+		}
+	}
+}
+
+func TestPatternMatchesFeatures(t *testing.T) {
+	data := &pattern.Pattern{
+		Header: pattern.Header{
+			Features: []pattern.Feature{pattern.Vibrate1, pattern.Vibrate2},
+		},
+	}
+
+	tests := []struct {
+		toyTag string
+		expect bool
+	}{
+		{"v1,v2", true},
+		{"v2,v1", false},
+		{"v", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		meta := &Pattern{ToyTag: test.toyTag}
+		if matches := meta.MatchesFeatures(data); matches != test.expect {
+			t.Errorf("ToyTag %q: expected %v, got %v", test.toyTag, test.expect, matches)
+		}
+	}
+}
+
+func TestCanLayer(t *testing.T) {
+	device := []pattern.Feature{pattern.Vibrate1, pattern.Vibrate2, pattern.Rotate}
+
+	tests := []struct {
+		a, b   string
+		expect bool
+	}{
+		{"v1", "v2", true},
+		{"v1,v2", "r", true},
+		{"v1", "v1,r", false},
+		{"v1", "p", false},
+		{"v1", "", false},
+	}
+
+	for _, test := range tests {
+		a := &Pattern{ToyTag: test.a}
+		b := &Pattern{ToyTag: test.b}
+		if can := CanLayer(device, a, b); can != test.expect {
+			t.Errorf("%q and %q: expected %v, got %v", test.a, test.b, test.expect, can)
+		}
+	}
+}
+
+func TestPatternFindTypeValid(t *testing.T) {
+	for _, typ := range AllFindTypes {
+		if !typ.Valid() {
+			t.Errorf("expected %q to be valid", typ)
+		}
+	}
+
+	if PatternFindType("recommended").Valid() {
+		t.Error("expected a lowercase typo to be invalid")
+	}
+
+	c := NewPatternClient(newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unexpected request for invalid find type")
+	}))
+
+	if _, err := c.Find(1, 0, "recommended"); err == nil {
+		t.Error("expected error finding with an invalid type")
+	}
+}
+
+func TestPatternTimerSeconds(t *testing.T) {
+	tests := []struct {
+		timer  string
+		secs   int
+		expect bool
+	}{
+		{"", 0, false},
+		{"30", 30, true},
+		{" 5 ", 5, true},
+		{"true", 0, false},
+	}
+
+	for _, test := range tests {
+		p := &Pattern{Timer: test.timer}
+		if secs, ok := p.TimerSeconds(); secs != test.secs || ok != test.expect {
+			t.Errorf("%q: expected (%d, %v), got (%d, %v)", test.timer, test.secs, test.expect, secs, ok)
+		}
+	}
+}
+
+func TestPatternClientValidators(t *testing.T) {
+	const etag = `"abc"`
+	var requests int
+
+	c := NewPatternClient(newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		io.WriteString(w, "V:1;F:v;#0;1;2;")
+	}), WithPatternCache(1), WithValidatorStore(&MemoryValidatorStore{}))
+
+	p := &Pattern{ID: "a", CDNPath: "/a"}
+
+	first, err := c.DownloadPattern(p)
+	if err != nil {
+		t.Fatal("cannot download pattern:", err)
+	}
+
+	second, err := c.DownloadPattern(p)
+	if !errors.Is(err, ErrNotModified) {
+		t.Fatalf("expected ErrNotModified, got %v", err)
+	}
+
+	if second != first {
+		t.Error("expected the cached pattern to be returned")
+	}
+
+	if requests != 2 {
+		t.Errorf("expected cached pattern to be revalidated, got %d requests", requests)
+	}
+}
+
+func TestPatternClientValidatorsUncached(t *testing.T) {
+	const etag = `"abc"`
+
+	store := &MemoryValidatorStore{}
+
+	c := NewPatternClient(newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		io.WriteString(w, "V:1;F:v;#0;1;2;")
+	}), WithPatternCache(1), WithValidatorStore(store))
+
+	a := &Pattern{ID: "a", CDNPath: "/a"}
+	b := &Pattern{ID: "b", CDNPath: "/b"}
+
+	tests := []struct {
+		name  string
+		evict func()
+	}{
+		{"evicted", func() {
+			if _, err := c.DownloadPattern(b); err != nil {
+				t.Fatal("cannot download other pattern:", err)
+			}
+		}},
+		{"invalidated", func() { c.InvalidateCache("a") }},
+		{"cleared", func() { c.ClearCache() }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := c.DownloadPattern(a); err != nil && !errors.Is(err, ErrNotModified) {
+				t.Fatal("cannot download pattern:", err)
+			}
+
+			test.evict()
+
+			p, err := c.DownloadPattern(a)
+			if err != nil {
+				t.Fatal("cannot download pattern after it left the cache:", err)
+			}
+			if p == nil {
+				t.Fatal("expected a pattern")
+			}
+		})
+	}
+
+	c.InvalidateCache("a")
+	if _, ok := store.LoadValidators("a"); ok {
+		t.Error("expected InvalidateCache to delete the validators")
+	}
+}
+
 func testLogPatterns(t *testing.T, patterns []Pattern) {
 	for i, pattern := range patterns {
 		t.Logf(
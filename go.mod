@@ -1,5 +1,5 @@
 module github.com/diamondburned/go-lovense
 
-go 1.17
+go 1.18
 
 require github.com/go-test/deep v1.0.8
@@ -0,0 +1,104 @@
+package pattern
+
+import (
+	"fmt"
+	"time"
+)
+
+// PatternBuilder builds a Pattern. Its methods can be chained, and any error
+// is reported by Build.
+type PatternBuilder struct {
+	pattern Pattern
+	err     error
+}
+
+// NewPatternBuilder creates a new PatternBuilder. It starts with a version 1
+// header with a single vibrator and an interval of 100ms.
+func NewPatternBuilder() *PatternBuilder {
+	return &PatternBuilder{
+		pattern: Pattern{
+			Header: Header{
+				Version:  V1,
+				Features: []Feature{Vibrate},
+				Interval: DefaultInterval,
+			},
+		},
+	}
+}
+
+// SetVersion sets the pattern's version.
+func (b *PatternBuilder) SetVersion(v Version) *PatternBuilder {
+	b.pattern.Version = v
+	return b
+}
+
+// SetType sets the pattern's type.
+func (b *PatternBuilder) SetType(typ string) *PatternBuilder {
+	b.pattern.Type = typ
+	return b
+}
+
+// SetFeatures sets the pattern's features. Each point must have as many
+// strengths as there are features.
+func (b *PatternBuilder) SetFeatures(features ...Feature) *PatternBuilder {
+	b.pattern.Features = append([]Feature(nil), features...)
+	return b
+}
+
+// SetInterval sets the duration between each point.
+func (b *PatternBuilder) SetInterval(interval time.Duration) *PatternBuilder {
+	b.pattern.Interval = interval
+	return b
+}
+
+// AddPoint adds a point with the given strengths, one per motor. The point
+// must not be empty and must have as many strengths as the previous points.
+func (b *PatternBuilder) AddPoint(strengths ...Strength) *PatternBuilder {
+	if b.err != nil {
+		return b
+	}
+
+	if len(strengths) == 0 {
+		b.err = fmt.Errorf("point %d is empty: %w", len(b.pattern.Points), ErrInvalidStride)
+		return b
+	}
+
+	if stride := b.pattern.Points.Stride(); stride > 0 && len(strengths) != stride {
+		b.err = fmt.Errorf(
+			"point %d has %d motors, expected %d",
+			len(b.pattern.Points), len(strengths), stride,
+		)
+		return b
+	}
+
+	b.pattern.Points = append(b.pattern.Points, append(Point(nil), strengths...))
+	return b
+}
+
+// Build returns the built pattern. It returns an error if any point doesn't
+// have as many strengths as there are features.
+func (b *PatternBuilder) Build() (*Pattern, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	p := b.pattern
+	p.Features = append([]Feature(nil), p.Features...)
+	p.Points = append(Points(nil), p.Points...)
+
+	if p.Version == V0 && len(p.Features) != 1 {
+		return nil, fmt.Errorf("v0 pattern has %d features, expected 1", len(p.Features))
+	}
+
+	for _, point := range p.Points {
+		if len(point) != len(p.Features) {
+			return nil, &FeatureMismatchError{
+				Type:     p.Type,
+				Declared: len(p.Features),
+				Actual:   len(point),
+			}
+		}
+	}
+
+	return &p, nil
+}
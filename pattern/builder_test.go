@@ -0,0 +1,76 @@
+package pattern
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestPatternBuilder(t *testing.T) {
+	p, err := NewPatternBuilder().
+		SetType("Edge").
+		SetFeatures(Vibrate1, Vibrate2).
+		SetInterval(200*time.Millisecond).
+		AddPoint(0, 1).
+		AddPoint(20, 20).
+		Build()
+	if err != nil {
+		t.Fatal("cannot build pattern:", err)
+	}
+
+	expect := &Pattern{
+		Header: Header{
+			Version:  V1,
+			Type:     "Edge",
+			Features: []Feature{Vibrate1, Vibrate2},
+			Interval: 200 * time.Millisecond,
+		},
+		Points: Points{{0, 1}, {20, 20}},
+	}
+
+	if diff := deep.Equal(p, expect); diff != nil {
+		t.Fatalf("unexpected pattern: %s", diff)
+	}
+}
+
+func TestPatternBuilderErrors(t *testing.T) {
+	_, err := NewPatternBuilder().
+		SetFeatures(Vibrate1, Vibrate2).
+		AddPoint(0, 1).
+		AddPoint(0).
+		Build()
+	if err == nil {
+		t.Error("expected error adding points of differing strides")
+	}
+
+	_, err = NewPatternBuilder().
+		AddPoint(0, 1).
+		Build()
+
+	var mismatchErr *FeatureMismatchError
+	if !errors.As(err, &mismatchErr) {
+		t.Errorf("expected *FeatureMismatchError, got %v", err)
+	}
+
+	_, err = NewPatternBuilder().
+		AddPoint().
+		Build()
+	if !errors.Is(err, ErrInvalidStride) {
+		t.Errorf("expected ErrInvalidStride for an empty point, got %v", err)
+	}
+
+	// Points may be edited after being added, so Build must check every one
+	// of them rather than just the first.
+	builder := NewPatternBuilder().
+		SetFeatures(Vibrate1, Vibrate2).
+		AddPoint(0, 1).
+		AddPoint(2, 3)
+	builder.pattern.Points[1] = Point{2}
+
+	_, err = builder.Build()
+	if !errors.As(err, &mismatchErr) {
+		t.Errorf("expected *FeatureMismatchError, got %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package pattern
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// featureCommands maps each feature to its command in Lovense's Standard API
+// and the maximum level that the command takes. Levels start from 0.
+var featureCommands = map[Feature]struct {
+	name string
+	max  int
+}{
+	Vibrate:  {"Vibrate", 20},
+	Vibrate1: {"Vibrate1", 20},
+	Vibrate2: {"Vibrate2", 20},
+	Rotate:   {"Rotate", 20},
+	AirPump:  {"Pump", 3},
+}
+
+// Commands returns the command for each point in the format of Lovense's
+// Standard API, e.g. "Vibrate1:10,Vibrate2:5". Each command should be sent
+// every Interval.
+//
+// The levels are within [0, 20] for the vibrators and rotation, and [0, 3] for
+// the air pump. Strengths are scaled according to the pattern's version before
+// being converted to levels.
+func (p *Pattern) Commands() ([]string, error) {
+	if stride := p.Points.Stride(); stride > 0 && stride != len(p.Features) {
+		return nil, &FeatureMismatchError{
+			Type:     p.Type,
+			Declared: len(p.Features),
+			Actual:   stride,
+		}
+	}
+
+	for _, f := range p.Features {
+		if _, ok := featureCommands[f]; !ok {
+			return nil, fmt.Errorf("no command for feature %q", string(f))
+		}
+	}
+
+	commands := make([]string, len(p.Points))
+
+	var b strings.Builder
+	for i, point := range p.Points {
+		b.Reset()
+
+		for j, s := range point {
+			cmd := featureCommands[p.Features[j]]
+			level := math.Round(s.Scale(p.Version) * float64(cmd.max))
+
+			if j > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(cmd.name)
+			b.WriteByte(':')
+			b.WriteString(strconv.Itoa(int(level)))
+		}
+
+		commands[i] = b.String()
+	}
+
+	return commands, nil
+}
+
+// MaxLevel is the maximum level that Lovense toys take for vibration.
+const MaxLevel = 20
+
+// Levels returns the level of the given motor at each point, within [0,
+// MaxLevel], which is what Lovense toys take for vibration. The strengths are
+// scaled according to the pattern's version before being rounded to the
+// nearest level.
+func (p *Pattern) Levels(motor int) ([]int, error) {
+	if stride := p.Points.Stride(); motor < 0 || motor >= stride {
+		return nil, fmt.Errorf("motor %d out of range [0, %d)", motor, stride)
+	}
+
+	levels := make([]int, len(p.Points))
+	for i, point := range p.Points {
+		if motor >= len(point) {
+			return nil, fmt.Errorf("point %d has %d motors, expected at least %d", i, len(point), motor+1)
+		}
+		levels[i] = int(math.Round(point[motor].Scale(p.Version) * MaxLevel))
+	}
+
+	return levels, nil
+}
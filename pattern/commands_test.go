@@ -0,0 +1,81 @@
+package pattern
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestPatternCommands(t *testing.T) {
+	p := &Pattern{
+		Header: Header{
+			Version:  V1,
+			Features: []Feature{Vibrate1, AirPump},
+		},
+		Points: Points{{0, 0}, {10, 10}, {20, 20}},
+	}
+
+	commands, err := p.Commands()
+	if err != nil {
+		t.Fatal("cannot get commands:", err)
+	}
+
+	expect := []string{
+		"Vibrate1:0,Pump:0",
+		"Vibrate1:10,Pump:2",
+		"Vibrate1:20,Pump:3",
+	}
+
+	if diff := deep.Equal(commands, expect); diff != nil {
+		t.Fatalf("unexpected commands: %s", diff)
+	}
+
+	p.Features = []Feature{Vibrate1, "x"}
+	if _, err := p.Commands(); err == nil {
+		t.Error("expected error for unknown feature")
+	}
+}
+
+func TestPatternLevels(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern *Pattern
+		motor   int
+		expect  []int
+	}{
+		{
+			name:    "v0",
+			pattern: &Pattern{Header: Header{Version: V0}, Points: Points{{0}, {50}, {100}, {3}}},
+			motor:   0,
+			expect:  []int{0, 10, 20, 1},
+		},
+		{
+			name:    "v1",
+			pattern: &Pattern{Header: Header{Version: V1}, Points: Points{{0, 20}, {10, 5}}},
+			motor:   1,
+			expect:  []int{20, 5},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			levels, err := test.pattern.Levels(test.motor)
+			if err != nil {
+				t.Fatal("cannot get levels:", err)
+			}
+
+			if diff := deep.Equal(levels, test.expect); diff != nil {
+				t.Fatalf("unexpected levels: %s", diff)
+			}
+		})
+	}
+
+	p := &Pattern{Header: Header{Version: V1}, Points: Points{{0, 1}, {2}}}
+
+	if _, err := p.Levels(2); err == nil {
+		t.Fatal("expected error for out of range motor")
+	}
+	if _, err := p.Levels(1); err == nil {
+		t.Fatal("expected error for point with fewer motors")
+	}
+}
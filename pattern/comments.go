@@ -0,0 +1,46 @@
+package pattern
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// StripComments returns a reader that reads r with every line starting with
+// prefix removed, including its line ending. Spaces and tabs before prefix are
+// ignored. Official pattern files have no comments, but this allows annotating
+// hand-written ones. The returned reader can be given to NewReader or Parse.
+func StripComments(r io.Reader, prefix string) io.Reader {
+	return &commentReader{
+		buf:    bufio.NewReader(r),
+		prefix: []byte(prefix),
+	}
+}
+
+type commentReader struct {
+	buf     *bufio.Reader
+	prefix  []byte
+	pending []byte
+	err     error
+}
+
+func (r *commentReader) Read(b []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		var line []byte
+		line, r.err = r.buf.ReadBytes('\n')
+
+		if len(r.prefix) > 0 && bytes.HasPrefix(bytes.TrimLeft(line, " \t"), r.prefix) {
+			continue
+		}
+
+		r.pending = line
+	}
+
+	n := copy(b, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
@@ -0,0 +1,40 @@
+package pattern
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestStripComments(t *testing.T) {
+	const input = "// Edge warmup.\nV:1;T:Edge;F:v1,v2;S:100;M:;#\n  // ramp up\n0,1;1,0;\n// hold\n20,20;"
+
+	b, err := io.ReadAll(StripComments(strings.NewReader(input), "//"))
+	if err != nil {
+		t.Fatal("cannot read:", err)
+	}
+
+	const expect = "V:1;T:Edge;F:v1,v2;S:100;M:;#\n0,1;1,0;\n20,20;"
+	if string(b) != expect {
+		t.Fatalf("expected %q, got %q", expect, b)
+	}
+}
+
+func TestParseCommented(t *testing.T) {
+	const input = "// 3 points\n0,50,\n// the end\n100,"
+
+	p, err := ParseCommented(strings.NewReader(input), "//")
+	if err != nil {
+		t.Fatal("cannot parse pattern:", err)
+	}
+
+	if diff := deep.Equal(p.Points, Points{{0}, {50}, {100}}); diff != nil {
+		t.Fatalf("unexpected points: %s", diff)
+	}
+
+	if _, err := Parse(strings.NewReader(input)); err == nil {
+		t.Fatal("expected Parse to reject comments")
+	}
+}
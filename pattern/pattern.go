@@ -6,19 +6,143 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// Errors that may be returned by Parse and Reader. They may be wrapped, so
+// errors.Is should be used to check for them.
+var (
+	// ErrEmptyPattern is returned when the pattern file has no content at
+	// all.
+	ErrEmptyPattern = errors.New("empty pattern")
+	// ErrUnknownVersion is returned when the pattern file's version isn't V0
+	// or V1.
+	ErrUnknownVersion = errors.New("unknown version")
+	// ErrFeatureMismatch is returned when the number of features in the
+	// header doesn't match the number of motors in the points. The
+	// *FeatureMismatchError that carries more details matches it.
+	ErrFeatureMismatch = errors.New("feature mismatch")
+	// ErrTruncatedPoints is returned when the last tuple of a version 1
+	// pattern file is cut off, which usually means the file is incomplete.
+	ErrTruncatedPoints = errors.New("truncated points")
+	// ErrInvalidStride is returned when the number of points in each tuple of
+	// a version 1 pattern file cannot be determined.
+	ErrInvalidStride = errors.New("invalid stride")
+	// ErrMalformed is returned when the pattern file doesn't follow the
+	// format, such as when the header has no delimiter or an out-of-range
+	// value, or when a point has the wrong number of motors.
+	ErrMalformed = errors.New("malformed pattern")
+)
+
 // Pattern describes a pattern file.
 type Pattern struct {
 	Header
 	Points Points
+
+	// Raw is the pattern file exactly as it was read. It is only set by
+	// ParseRaw and isn't updated when the pattern is modified.
+	Raw []byte
 }
 
 // Parse consumes r fully and returns the Lovense pattern reader and all its
 // points. It adds onto Reader a few guarantees.
+//
+// If a version 1 pattern file doesn't declare any features, placeholder
+// features are made up from the number of motors in the points: Vibrate for a
+// single motor, or Vibrate1, Vibrate2 and so on for more.
 func Parse(r io.Reader) (*Pattern, error) {
+	return parse(r, parseOpts{})
+}
+
+// ParseHeaderOnly reads only the header from r, leaving the points unread. This
+// is much cheaper than Parse if only the metadata is needed.
+func ParseHeaderOnly(r io.Reader) (Header, error) {
+	return NewReader(r).ReadHeader()
+}
+
+// ParseString is like Parse, but it parses the given string.
+func ParseString(s string) (*Pattern, error) {
+	return Parse(strings.NewReader(s))
+}
+
+// ParseBytes is like Parse, but it parses the given byte slice.
+func ParseBytes(b []byte) (*Pattern, error) {
+	return Parse(bytes.NewReader(b))
+}
+
+// ParseRaw is like Parse, except the whole pattern file is also kept in Raw, so
+// that it can be written back byte for byte with WriteRawTo. r is read into
+// memory before parsing.
+func ParseRaw(r io.Reader) (*Pattern, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read: %w", err)
+	}
+
+	p, err := ParseBytes(b)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Raw = b
+	return p, nil
+}
+
+// ParseCommented is like Parse, except lines starting with prefix, such as
+// "//", are skipped as comments. See StripComments.
+func ParseCommented(r io.Reader, prefix string) (*Pattern, error) {
+	return Parse(StripComments(r, prefix))
+}
+
+// ParseLenient is like Parse, except versions that aren't known are parsed
+// using the version 1 point reader instead of erroring out. The declared
+// version is kept in the header, so callers should be careful with scaling
+// the returned points.
+func ParseLenient(r io.Reader) (*Pattern, error) {
+	return parse(r, parseOpts{lenient: true})
+}
+
+// ParseRepair is like Parse, except version 1 tuples that don't have as many
+// strengths as most other tuples are repaired instead of erroring out: short
+// tuples are padded with zeros, and long ones are truncated. The indices of the
+// repaired points are returned.
+func ParseRepair(r io.Reader) (*Pattern, []int, error) {
+	var repaired []int
+
+	p, err := parse(r, parseOpts{repaired: &repaired})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return p, repaired, nil
+}
+
+// isFlat returns true if the points ahead should be read using
+// ReadAllFlatPoints with the given stride. If all of the points were seen, the
+// number of strengths must be a multiple of stride; otherwise, the points are
+// more likely a single tuple that is cut off or has the wrong number of motors.
+// Longer files are left to ReadAllFlatPoints to check.
+func isFlat(r *Reader, stride int) bool {
+	n, complete, ok := r.flatStrengths()
+	return ok && stride > 0 && (!complete || n%stride == 0)
+}
+
+// isCutOff returns true if the points ahead are a single unterminated tuple
+// with fewer strengths than stride.
+func isCutOff(r *Reader, stride int) bool {
+	n, complete, ok := r.flatStrengths()
+	return ok && complete && n < stride
+}
+
+type parseOpts struct {
+	lenient  bool
+	repaired *[]int // non-nil to repair v1 points
+}
+
+func parse(r io.Reader, opts parseOpts) (*Pattern, error) {
 	reader := NewReader(r)
 
 	h, err := reader.ReadHeader()
@@ -26,25 +150,54 @@ func Parse(r io.Reader) (*Pattern, error) {
 		return nil, fmt.Errorf("cannot read header: %w", err)
 	}
 
+	// Without any features, flat points can only be assumed to be for a
+	// single motor.
+	flatStride := len(h.Features)
+	if flatStride == 0 {
+		flatStride = 1
+	}
+
 	var p Points
 
-	switch h.Version {
-	case V0:
+	switch {
+	case h.Version == V0:
 		p, err = reader.ReadAllV0Points()
 		if err != nil {
 			return nil, fmt.Errorf("cannot read all v0 points: %w", err)
 		}
-	case V1:
+	case (h.Version == V1 || opts.lenient) && opts.repaired != nil:
+		p, *opts.repaired, err = reader.readAllV1PointsRepair()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read all v1 points: %w", err)
+		}
+	case (h.Version == V1 || opts.lenient) && isFlat(reader, flatStride):
+		p, err = reader.ReadAllFlatPoints(flatStride)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read all flat v1 points: %w", err)
+		}
+	case (h.Version == V1 || opts.lenient) && isCutOff(reader, len(h.Features)):
+		return nil, fmt.Errorf(
+			"cannot read all v1 points: %w: tuple doesn't have %d points",
+			ErrTruncatedPoints, len(h.Features))
+	case h.Version == V1 || opts.lenient:
 		p, err = reader.ReadAllV1Points()
 		if err != nil {
 			return nil, fmt.Errorf("cannot read all v1 points: %w", err)
 		}
-	case 2:
-		return nil, fmt.Errorf("unknown version %d", h.Version)
+	default:
+		return nil, fmt.Errorf("%w %d", ErrUnknownVersion, h.Version)
+	}
+
+	if len(h.Features) == 0 && len(p) > 0 {
+		h.Features = placeholderFeatures(len(p[0]))
 	}
 
 	if len(p) > 0 && len(p[0]) != len(h.Features) {
-		return nil, fmt.Errorf("mismatch: %d motors != %d in points", len(h.Features), len(p[0]))
+		return nil, &FeatureMismatchError{
+			Type:     h.Type,
+			Declared: len(h.Features),
+			Actual:   len(p[0]),
+		}
 	}
 
 	return &Pattern{
@@ -53,6 +206,42 @@ func Parse(r io.Reader) (*Pattern, error) {
 	}, nil
 }
 
+// placeholderFeatures returns the features of n motors for a pattern file that
+// doesn't declare any.
+func placeholderFeatures(n int) []Feature {
+	if n == 1 {
+		return []Feature{Vibrate}
+	}
+
+	features := make([]Feature, n)
+	for i := range features {
+		features[i] = Feature("v" + strconv.Itoa(i+1))
+	}
+	return features
+}
+
+// FeatureMismatchError is returned by Parse when the number of features
+// declared in the header doesn't match the number of motors in the points. It
+// implements error.
+type FeatureMismatchError struct {
+	Type     string // pattern type, or T
+	Declared int    // number of features in the header
+	Actual   int    // number of motors in each point
+}
+
+// Error implements error.
+func (e *FeatureMismatchError) Error() string {
+	return fmt.Sprintf(
+		"mismatch: %d motors != %d in points (type %q)",
+		e.Declared, e.Actual, e.Type,
+	)
+}
+
+// Is returns true if target is ErrFeatureMismatch.
+func (e *FeatureMismatchError) Is(target error) bool {
+	return target == ErrFeatureMismatch
+}
+
 // Version is the version of the pattern.
 type Version int
 
@@ -75,6 +264,55 @@ type Header struct {
 	Features []Feature     // F
 	Interval time.Duration // S
 	MD5Sum   string        // M
+
+	// Extra contains header fields with keys other than the ones above, such
+	// as those emitted by community tooling. It is nil if there are none.
+	Extra map[string]string
+}
+
+// Bounds for the Interval in a pattern file's header. ReadHeader errors out on
+// S values outside of these bounds.
+const (
+	MinInterval = time.Millisecond
+	MaxInterval = time.Minute
+)
+
+// DefaultInterval is the interval of version 0 pattern files, which don't
+// declare one.
+const DefaultInterval = 100 * time.Millisecond
+
+// EffectiveInterval returns the Interval, or DefaultInterval if it's not
+// positive. Headers returned by ReadHeader always have a valid Interval, but
+// hand-made ones might not, and playing them back at a zero interval would
+// spin.
+func (h Header) EffectiveInterval() time.Duration {
+	if h.Interval <= 0 {
+		return DefaultInterval
+	}
+	return h.Interval
+}
+
+// knownTypes maps the lowercased names of known toys, as seen in the T field,
+// to their canonical casing.
+var knownTypes = map[string]string{}
+
+func init() {
+	for _, name := range []string{
+		"Ambi", "Calor", "Diamo", "Dolce", "Domi", "Edge", "Exomoon", "Ferri",
+		"Flexer", "Gemini", "Gravity", "Gush", "Hush", "Hyphy", "Lapis", "Lush",
+		"Max", "Mission", "Nora", "Osci", "Ridge", "Solace", "Tenera", "Vulse",
+	} {
+		knownTypes[strings.ToLower(name)] = name
+	}
+}
+
+// NormalizedType returns the Type with known toy names in a consistent casing,
+// e.g. "edge" and "EDGE" both become "Edge". Unknown types are returned as-is.
+func (h Header) NormalizedType() string {
+	if name, ok := knownTypes[strings.ToLower(h.Type)]; ok {
+		return name
+	}
+	return h.Type
 }
 
 // Feature is the type for the values in the F field.
@@ -123,6 +361,13 @@ func (s Strength) Scale(v Version) float64 {
 	}
 }
 
+// ScaleGamma is like Scale, except the scaled strength is raised to the power
+// of gamma. A gamma above 1, such as 2.2, makes low strengths weaker, which
+// may feel more natural since they're barely noticeable to begin with.
+func (s Strength) ScaleGamma(v Version, gamma float64) float64 {
+	return clampF(math.Pow(s.Scale(v), gamma))
+}
+
 func clampF(f float64) float64 {
 	if f < 0 {
 		return 0
@@ -155,6 +400,22 @@ func (p Point) ScaleAppend(v Version, buf []float64) []float64 {
 	return buf
 }
 
+// String formats the point as its version 1 wire representation, which is its
+// strengths joined by commas, e.g. "20,0".
+func (p Point) String() string {
+	return string(p.appendWire(make([]byte, 0, len(p)*3)))
+}
+
+func (p Point) appendWire(b []byte) []byte {
+	for i, s := range p {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = strconv.AppendUint(b, uint64(s), 10)
+	}
+	return b
+}
+
 // Points contains a list of points, each containing a list of vibration
 // strength numbers. It holds multiple points representing multiple instants of
 // time incremented by the Interval.
@@ -162,16 +423,58 @@ type Points []Point
 
 // Reader provides a Lovense pattern reader.
 type Reader struct {
-	buf *bufio.Reader
+	buf     *bufio.Reader
+	scratch []byte
+	owned   bool // buf was created by the Reader, not given by the caller
 }
 
-// NewReader creates a new reader from the given io.Reader.
+// NewReader creates a new reader from the given io.Reader. If r is a
+// *bufio.Reader, it is read from directly instead of being wrapped in another
+// one.
 func NewReader(r io.Reader) *Reader {
 	buffer, ok := r.(*bufio.Reader)
 	if !ok {
-		buffer = bufio.NewReader(r)
+		return &Reader{buf: bufio.NewReader(r), owned: true}
 	}
-	return &Reader{buffer}
+	return &Reader{buf: buffer}
+}
+
+// Reset discards all state and makes the reader read from newR instead,
+// reusing its buffers. This avoids allocating a new Reader for each file. A
+// *bufio.Reader given to NewReader is never reset; the Reader switches to a
+// buffer of its own instead.
+func (r *Reader) Reset(newR io.Reader) {
+	r.scratch = r.scratch[:0]
+
+	switch buffer, ok := newR.(*bufio.Reader); {
+	case ok:
+		r.buf = buffer
+		r.owned = false
+	case r.owned:
+		r.buf.Reset(newR)
+	default:
+		r.buf = bufio.NewReader(newR)
+		r.owned = true
+	}
+}
+
+// readSlice is like bufio.Reader's ReadSlice, except it isn't limited by the
+// buffer size, so it works with data that arrives in small chunks. The
+// returned slice is only valid until the next read.
+func (r *Reader) readSlice(delim byte) ([]byte, error) {
+	b, err := r.buf.ReadSlice(delim)
+	if !errors.Is(err, bufio.ErrBufferFull) {
+		return b, err
+	}
+
+	r.scratch = append(r.scratch[:0], b...)
+
+	for errors.Is(err, bufio.ErrBufferFull) {
+		b, err = r.buf.ReadSlice(delim)
+		r.scratch = append(r.scratch, b...)
+	}
+
+	return r.scratch, err
 }
 
 var spaces = [255]bool{
@@ -181,19 +484,39 @@ var spaces = [255]bool{
 	'\r': true,
 }
 
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// skipBOM discards the UTF-8 byte order mark that some editors prepend to the
+// file, if there is one.
+func (r *Reader) skipBOM() {
+	b, _ := r.buf.Peek(len(utf8BOM))
+	if bytes.Equal(b, utf8BOM) {
+		r.buf.Discard(len(utf8BOM))
+	}
+}
+
 // ReadHeader reads the header. Note that the method will consume more bytes
 // from the io.Reader than it needs to, since the reader is buffered.
+//
+// Features is nil if a version 1 header has no or an empty F field.
 func (r *Reader) ReadHeader() (Header, error) {
 	header := Header{
 		Version:  0,
 		Features: []Feature{"v"},
-		Interval: 100 * time.Millisecond,
+		Interval: DefaultInterval,
 	}
 
+	r.skipBOM()
+
 	// Peek the next 2 bytes. If it's "V:", then we can read the version.
 	// Otherwise, it's version 0.
 	versionHeader, err := r.buf.Peek(2)
-	if err != nil {
+	// A version 0 pattern file with a single 1-digit point is shorter than
+	// the version header, so only error out if there's nothing at all.
+	if err != nil && (!errors.Is(err, io.EOF) || len(versionHeader) == 0) {
+		if errors.Is(err, io.EOF) {
+			return header, ErrEmptyPattern
+		}
 		return header, fmt.Errorf("cannot peek version: %w", err)
 	}
 
@@ -201,14 +524,12 @@ func (r *Reader) ReadHeader() (Header, error) {
 		return header, nil
 	}
 
-	// This reads maximum r.buf.Size() bytes.
-	b, err := r.buf.ReadSlice('#')
+	b, err := r.readHeaderBytes()
 	if err != nil {
 		return header, err
 	}
 
-	// Discard the delimiter byte.
-	b = bytes.TrimSuffix(b, []byte("#"))
+	var hasFeatures bool
 
 	fields := bytes.Split(b, []byte(";"))
 
@@ -222,64 +543,203 @@ func (r *Reader) ReadHeader() (Header, error) {
 		case "V":
 			v, err := strconv.Atoi(string(parts[1]))
 			if err != nil {
-				return header, fmt.Errorf("invalid version %q: %v", parts[1], err)
+				return header, fmt.Errorf("invalid version %q: %w", parts[1], err)
 			}
 			header.Version = Version(v)
 		case "T":
 			header.Type = string(parts[1])
 		case "F":
-			motors := bytes.Split(parts[1], []byte(","))
-			header.Features = make([]Feature, len(motors))
-			for i, motor := range motors {
-				header.Features[i] = Feature(motor)
+			header.Features = nil
+			for _, motor := range bytes.Split(parts[1], []byte(",")) {
+				if len(motor) > 0 {
+					header.Features = append(header.Features, Feature(motor))
+				}
 			}
+			hasFeatures = true
 		case "S":
 			d, err := strconv.Atoi(string(parts[1]))
 			if err != nil {
-				return header, fmt.Errorf("invalid S value %q: %v", parts[1], err)
+				return header, fmt.Errorf("invalid S value %q: %w", parts[1], err)
+			}
+			// Check the bounds before converting to avoid overflowing.
+			if d < int(MinInterval/time.Millisecond) || d > int(MaxInterval/time.Millisecond) {
+				return header, fmt.Errorf(
+					"%w: S value %d out of range [%v, %v]", ErrMalformed, d, MinInterval, MaxInterval)
 			}
 			header.Interval = time.Duration(d) * time.Millisecond
 		case "M":
 			header.MD5Sum = string(parts[1])
+		default:
+			if header.Extra == nil {
+				header.Extra = make(map[string]string, 1)
+			}
+			header.Extra[string(parts[0])] = string(parts[1])
 		}
 	}
 
+	// Only version 0 pattern files are known to have a single vibrator, so
+	// don't assume the default features for other versions.
+	if !hasFeatures && header.Version != V0 {
+		header.Features = nil
+	}
+
 	return header, nil
 }
 
+// SkipHeader discards the header, leaving the reader positioned at the first
+// point. It does nothing for version 0 pattern files, since they don't have a
+// header.
+func (r *Reader) SkipHeader() error {
+	r.skipBOM()
+
+	versionHeader, err := r.buf.Peek(2)
+	// A version 0 pattern file with a single 1-digit point is shorter than
+	// the version header, so only error out if there's nothing at all.
+	if err != nil && (!errors.Is(err, io.EOF) || len(versionHeader) == 0) {
+		if errors.Is(err, io.EOF) {
+			return ErrEmptyPattern
+		}
+		return fmt.Errorf("cannot peek version: %w", err)
+	}
+
+	if string(versionHeader) != "V:" {
+		return nil
+	}
+
+	_, err = r.readHeaderBytes()
+	return err
+}
+
+// readHeaderBytes reads the header up to and excluding the '#' delimiter. If
+// the delimiter is missing, which happens with hand-edited files, then the
+// first line is used as the header instead, as long as there are points after
+// it; otherwise, the points may be on the header line and would be lost.
+func (r *Reader) readHeaderBytes() ([]byte, error) {
+	b, err := r.readSlice('#')
+	if err == nil {
+		return bytes.TrimSuffix(b, []byte("#")), nil
+	}
+
+	if errors.Is(err, io.EOF) {
+		if i := bytes.IndexByte(b, '\n'); i != -1 && len(bytes.TrimSpace(b[i+1:])) > 0 {
+			// We've read until EOF, so put everything after the first line
+			// back for the point readers. b may be owned by r, so copy it
+			// before resetting. Reset leaves a caller's bufio.Reader alone.
+			header := bytes.TrimSpace(append([]byte(nil), b[:i]...))
+			r.Reset(bytes.NewReader(append([]byte(nil), b[i+1:]...)))
+			return header, nil
+		}
+		return nil, fmt.Errorf("%w: cannot find header delimiter '#'", ErrMalformed)
+	}
+
+	return nil, fmt.Errorf("cannot find header delimiter '#': %w", err)
+}
+
 // ReadAllV0Points reads all data points in a version 0 pattern file.
 // Version 0 is not capable of containing data for more than 1 motor, so the
 // length of the inner slice is always 1.
 func (r *Reader) ReadAllV0Points() (Points, error) {
-	var points Points
+	// Version 0 pattern files are small enough to be read into memory whole,
+	// which is much faster than reading them point by point.
+	b, err := io.ReadAll(r.buf)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read v0 points: %w", err)
+	}
+
+	n := bytes.Count(b, []byte(",")) + 1
+
+	// backing slice that contains all points, each of which has length 1
+	backing := make([]Strength, 0, n)
+	points := make(Points, 0, n)
+
+	pr := sepReader{b: b, s: ','}
+	for v := pr.next(); v != nil; v = pr.next() {
+		v = bytes.TrimSpace(v)
+		if len(v) == 0 {
+			continue
+		}
+
+		p, err := strconv.ParseUint(string(v), 10, 8)
+		if err != nil {
+			return points, fmt.Errorf("error parsing v0 point: %w", err)
+		}
+
+		backing = append(backing, Strength(p))
+		points = append(points, backing[len(backing)-1:len(backing):len(backing)])
+	}
+
+	return points, nil
+}
+
+// flatStrengths returns the number of strengths ahead if they don't seem to
+// have any tuple separators, meaning that they may have to be read using
+// ReadAllFlatPoints. ok is false if there's a separator. Only what fits in the
+// buffer is checked, since normal files have a separator every few bytes;
+// complete is false if there's more after that, in which case n is unknown.
+func (r *Reader) flatStrengths() (n int, complete, ok bool) {
+	b, err := r.buf.Peek(r.buf.Size())
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, false, false
+	}
+
+	if bytes.IndexByte(b, ';') != -1 || len(bytes.TrimSpace(b)) == 0 {
+		return 0, false, false
+	}
 
-	// Peak to get the size for preallocating backing. We'll leave getting the
-	// stride to the actual loop.
-	b, err := r.buf.Peek(r.buf.Buffered())
 	if err == nil {
-		n := bytes.Count(b, []byte(",")) + 1
-		points = make(Points, 0, n)
+		return 0, false, true
 	}
 
-	for err == nil {
-		b, err = r.buf.ReadSlice(',')
-		if err != nil && !errors.Is(err, io.EOF) {
-			return points, fmt.Errorf("cannot read v0 point: %w", err)
+	for _, v := range bytes.Split(b, []byte(",")) {
+		if len(bytes.TrimSpace(v)) > 0 {
+			n++
 		}
+	}
 
-		b = bytes.TrimSuffix(b, []byte(","))
-		b = bytes.TrimSpace(b)
+	return n, true, n > 0
+}
 
-		if len(b) == 0 {
+// ReadAllFlatPoints reads all data points in a version 1 pattern file whose
+// strengths are all separated by commas, without any semicolons between
+// tuples. The strengths are split into points of the given stride, which is
+// usually the number of features. It errors out if the number of strengths
+// isn't a multiple of stride.
+func (r *Reader) ReadAllFlatPoints(stride int) (Points, error) {
+	if stride <= 0 {
+		return nil, ErrInvalidStride
+	}
+
+	b, err := io.ReadAll(r.buf)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read flat points: %w", err)
+	}
+
+	// backing slice that contains all points flattened out
+	backing := make([]Strength, 0, bytes.Count(b, []byte(","))+1)
+
+	pr := sepReader{b: b, s: ','}
+	for v := pr.next(); v != nil; v = pr.next() {
+		v = bytes.TrimSpace(v)
+		if len(v) == 0 {
 			continue
 		}
 
-		p, err := strconv.ParseUint(string(b), 10, 8)
+		p, err := strconv.ParseUint(string(v), 10, 8)
 		if err != nil {
-			return points, fmt.Errorf("error parsing v0 point: %w", err)
+			return nil, fmt.Errorf("invalid point: %w", err)
 		}
 
-		points = append(points, Point{Strength(p)})
+		backing = append(backing, Strength(p))
+	}
+
+	if len(backing)%stride != 0 {
+		return nil, fmt.Errorf(
+			"%w: %d strengths isn't a multiple of %d", ErrInvalidStride, len(backing), stride)
+	}
+
+	points := make(Points, 0, len(backing)/stride)
+	for head := 0; head < len(backing); head += stride {
+		points = append(points, backing[head:head+stride:head+stride])
 	}
 
 	return points, nil
@@ -288,7 +748,7 @@ func (r *Reader) ReadAllV0Points() (Points, error) {
 // ReadV1Points reads a list of motor data points in a version 1 pattern file.
 func (r *Reader) ReadV1Points() (Point, error) {
 	// TODO: retry until EOF or valid to skip spaces.
-	b, err := r.buf.ReadSlice(';')
+	b, err := r.readSlice(';')
 	if err != nil {
 		return nil, err
 	}
@@ -299,7 +759,7 @@ func (r *Reader) ReadV1Points() (Point, error) {
 	for i, part := range parts {
 		v, err := strconv.Atoi(string(part))
 		if err != nil {
-			return nil, fmt.Errorf("invalid point %q: %v", part, err)
+			return nil, fmt.Errorf("invalid point %q: %w", part, err)
 		}
 		point[i] = Strength(v)
 	}
@@ -310,27 +770,32 @@ func (r *Reader) ReadV1Points() (Point, error) {
 // ReadAllV2Data reads all data points in a version 1 pattern file. It
 // guarantees that all point pairs in the slice will be equally sized.
 func (r *Reader) ReadAllV1Points() (Points, error) {
-	// backing slice that contains all points flattened out
+	return r.ReadAllV1PointsSep(';', ',')
+}
+
+// ReadAllV1PointsSep is like ReadAllV1Points, except tuples are separated by
+// tupleSep instead of ';', and the strengths within each tuple are separated by
+// motorSep instead of ','. This is useful for dialects produced by third-party
+// exporters.
+func (r *Reader) ReadAllV1PointsSep(tupleSep, motorSep byte) (Points, error) {
+	// backing slice that contains all points flattened out. It isn't
+	// preallocated, since what's buffered may only be a small part of the
+	// file if it's being streamed.
 	var backing []Strength
 	stride := -1
 
-	// Peak to get the size for preallocating backing. We'll leave getting the
-	// stride to the actual loop.
-	b, err := r.buf.Peek(r.buf.Buffered())
-	if err == nil {
-		n := bytes.Count(b, []byte(";")) + bytes.Count(b, []byte(",")) + 1
-		backing = make([]Strength, 0, n)
-	}
+	var b []byte
+	var err error
 
 	for err == nil {
-		b, err = r.buf.ReadSlice(';')
+		b, err = r.readSlice(tupleSep)
 		if err != nil && !errors.Is(err, io.EOF) {
 			// Early bail if the error isn't EOF.
 			return nil, fmt.Errorf("cannot read: %w", err)
 		}
 
-		// Trim the trailing semicolon out, since ReadSlice includes it.
-		b = bytes.TrimSuffix(b, []byte(";"))
+		// Trim the trailing separator out, since readSlice includes it.
+		b = bytes.TrimSuffix(b, []byte{tupleSep})
 		b = bytes.TrimSpace(b)
 
 		if len(b) == 0 {
@@ -341,14 +806,27 @@ func (r *Reader) ReadAllV1Points() (Points, error) {
 		if stride == -1 {
 			// Add 1, since each number gets its comma except for the first
 			// one.
-			stride = bytes.Count(b, []byte(",")) + 1
+			stride = bytes.Count(b, []byte{motorSep}) + 1
+
+			// A tuple made of only separators would give us a stride with no
+			// values in it.
+			if len(bytes.Trim(b, string(motorSep)+" \t")) == 0 {
+				return nil, fmt.Errorf("%w: %q has no points", ErrInvalidStride, b)
+			}
+		}
+
+		// The last tuple isn't terminated by a separator, so it may have been
+		// cut off.
+		if errors.Is(err, io.EOF) &&
+			(bytes.HasSuffix(b, []byte{motorSep}) || bytes.Count(b, []byte{motorSep})+1 < stride) {
+			return nil, fmt.Errorf("%w: %q doesn't have %d points", ErrTruncatedPoints, b, stride)
 		}
 
-		pr := sepReader{b: b, s: ','}
+		pr := sepReader{b: b, s: motorSep}
 		for i := 0; i < stride; i++ {
 			v := pr.next()
 			if v == nil {
-				return nil, fmt.Errorf("%q doesn't have %d points", b, stride)
+				return nil, fmt.Errorf("%w: %q doesn't have %d points", ErrMalformed, b, stride)
 			}
 
 			p, err := strconv.ParseUint(string(v), 10, 8)
@@ -358,6 +836,14 @@ func (r *Reader) ReadAllV1Points() (Points, error) {
 
 			backing = append(backing, Strength(p))
 		}
+
+		if pr.next() != nil {
+			return nil, fmt.Errorf("%w: %q has more than %d points", ErrMalformed, b, stride)
+		}
+	}
+
+	if len(backing) == 0 {
+		return make(Points, 0), nil
 	}
 
 	pairs := make(Points, 0, len(backing)/stride)
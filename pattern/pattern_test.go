@@ -2,10 +2,14 @@ package pattern
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"io"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/go-test/deep"
@@ -36,6 +40,45 @@ func TestSepReader(t *testing.T) {
 	}
 }
 
+func TestStrengthScaleGamma(t *testing.T) {
+	tests := []struct {
+		strength Strength
+		version  Version
+		gamma    float64
+		expect   float64
+	}{
+		{0, V1, 2, 0},
+		{10, V1, 2, 0.25},
+		{20, V1, 2, 1},
+		{50, V0, 1, 0.5},
+		{200, V0, 2.2, 1},
+	}
+
+	for _, test := range tests {
+		if v := test.strength.ScaleGamma(test.version, test.gamma); v != test.expect {
+			t.Errorf("%d^%v in %v: expected %v, got %v", test.strength, test.gamma, test.version, test.expect, v)
+		}
+	}
+}
+
+func TestPointString(t *testing.T) {
+	tests := []struct {
+		point  Point
+		expect string
+	}{
+		{nil, ""},
+		{Point{8}, "8"},
+		{Point{20, 0}, "20,0"},
+		{Point{255, 100, 0}, "255,100,0"},
+	}
+
+	for _, test := range tests {
+		if s := test.point.String(); s != test.expect {
+			t.Errorf("expected %q, got %q", test.expect, s)
+		}
+	}
+}
+
 func TestParseV1(t *testing.T) {
 	f := openFile(t, "testdata/edge")
 	b := bufio.NewReaderSize(f, 38)
@@ -66,6 +109,148 @@ func TestParseV1(t *testing.T) {
 	}
 }
 
+func TestParseTrailingSeparators(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		expect Points
+	}{
+		{"v0 trailing", "1,2,3,", Points{{1}, {2}, {3}}},
+		{"v0 no trailing", "1,2,3", Points{{1}, {2}, {3}}},
+		{"v0 trailing newline", "1,2,3,\n", Points{{1}, {2}, {3}}},
+		{"v0 no trailing newline", "1,2,3\n", Points{{1}, {2}, {3}}},
+		{"v0 single", "7", Points{{7}}},
+		{"v1 trailing", "V:1;F:v1,v2;#1,2;3,4;", Points{{1, 2}, {3, 4}}},
+		{"v1 no trailing", "V:1;F:v1,v2;#1,2;3,4", Points{{1, 2}, {3, 4}}},
+		{"v1 double trailing", "V:1;F:v1,v2;#1,2;3,4;;\n", Points{{1, 2}, {3, 4}}},
+		{"v1 no trailing newline", "V:1;F:v1,v2;#\n1,2;3,4\n", Points{{1, 2}, {3, 4}}},
+		{"v1 single", "V:1;F:v1,v2;#1,2", Points{{1, 2}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := ParseString(test.input)
+			if err != nil {
+				t.Fatal("cannot parse:", err)
+			}
+
+			if diff := deep.Equal(p.Points, test.expect); diff != nil {
+				t.Fatalf("unexpected points: %s", diff)
+			}
+		})
+	}
+}
+
+func TestParseFlat(t *testing.T) {
+	p, err := ParseString("V:1;F:v1,v2;#\n0,1,20,0,5,5,\n")
+	if err != nil {
+		t.Fatal("cannot parse flat points:", err)
+	}
+
+	if diff := deep.Equal(p.Points, Points{{0, 1}, {20, 0}, {5, 5}}); diff != nil {
+		t.Fatalf("unexpected points: %s", diff)
+	}
+
+	_, err = NewReader(strings.NewReader("0,1,2")).ReadAllFlatPoints(2)
+	if !errors.Is(err, ErrInvalidStride) {
+		t.Fatalf("expected ErrInvalidStride, got %v", err)
+	}
+}
+
+func TestParseFlatLarge(t *testing.T) {
+	for _, n := range []int{1000, 3000} {
+		input := "V:1;T:Edge;F:v1,v2;S:100;M:;#\n" + strings.Repeat("10,10,", n)
+
+		p, err := ParseString(input)
+		if err != nil {
+			t.Fatalf("%d points: cannot parse flat points: %v", n, err)
+		}
+
+		if len(p.Points) != n || !p.Points[n-1].Equal(Point{10, 10}) {
+			t.Fatalf("%d points: unexpected points, got %d", n, len(p.Points))
+		}
+	}
+
+	input := "V:1;F:v1,v2;S:100;#" + strings.Repeat("10,10,", 1000) + "10"
+	if _, err := ParseString(input); !errors.Is(err, ErrInvalidStride) {
+		t.Fatalf("expected ErrInvalidStride, got %v", err)
+	}
+}
+
+func TestParseFlatSingleTuple(t *testing.T) {
+	_, err := ParseString("V:1;F:v1,v2;S:100;#0")
+	if !errors.Is(err, ErrTruncatedPoints) {
+		t.Fatalf("expected ErrTruncatedPoints, got %v", err)
+	}
+
+	_, err = ParseString("V:1;F:v1,v2;S:100;#0,1,2")
+	var mismatchErr *FeatureMismatchError
+	if !errors.As(err, &mismatchErr) {
+		t.Fatalf("expected *FeatureMismatchError, got %v", err)
+	}
+}
+
+func TestReaderReset(t *testing.T) {
+	r := NewReader(strings.NewReader("V:1;T:First;F:v;#0;1;"))
+	if _, err := r.ReadHeader(); err != nil {
+		t.Fatal("cannot read first header:", err)
+	}
+
+	r.Reset(strings.NewReader("V:1;T:Second;F:v1,v2;#2,3;"))
+
+	h, err := r.ReadHeader()
+	if err != nil {
+		t.Fatal("cannot read second header:", err)
+	}
+	if h.Type != "Second" {
+		t.Errorf("expected type Second, got %q", h.Type)
+	}
+
+	p, err := r.ReadAllV1Points()
+	if err != nil {
+		t.Fatal("cannot read second points:", err)
+	}
+	if diff := deep.Equal(p, Points{{2, 3}}); diff != nil {
+		t.Fatalf("unexpected points: %s", diff)
+	}
+}
+
+func TestReaderResetBorrowed(t *testing.T) {
+	b := bufio.NewReader(strings.NewReader("0,1,"))
+
+	r := NewReader(b)
+	r.Reset(strings.NewReader("5,"))
+
+	p, err := r.ReadAllV0Points()
+	if err != nil {
+		t.Fatal("cannot read points:", err)
+	}
+	if diff := deep.Equal(p, Points{{5}}); diff != nil {
+		t.Fatalf("unexpected points: %s", diff)
+	}
+
+	rest, err := io.ReadAll(b)
+	if err != nil {
+		t.Fatal("cannot read caller's reader:", err)
+	}
+	if string(rest) != "0,1," {
+		t.Fatalf("Reset modified the caller's bufio.Reader, it now reads %q", rest)
+	}
+}
+
+func TestReadAllV1PointsSep(t *testing.T) {
+	r := NewReader(strings.NewReader("0 1|20 0|5 5|"))
+
+	p, err := r.ReadAllV1PointsSep('|', ' ')
+	if err != nil {
+		t.Fatal("cannot read points:", err)
+	}
+
+	if diff := deep.Equal(p, Points{{0, 1}, {20, 0}, {5, 5}}); diff != nil {
+		t.Fatalf("unexpected points: %s", diff)
+	}
+}
+
 func TestParseV0(t *testing.T) {
 	f := openFile(t, "testdata/v0")
 	b := bufio.NewReaderSize(f, 12)
@@ -101,6 +286,189 @@ func TestParseV0(t *testing.T) {
 	}
 }
 
+func TestParseHeaderOnly(t *testing.T) {
+	h, err := ParseHeaderOnly(openFile(t, "testdata/edge"))
+	if err != nil {
+		t.Fatal("cannot parse header:", err)
+	}
+
+	expect := Header{
+		Version:  1,
+		Type:     "Edge",
+		Features: []Feature{Vibrate1, Vibrate2},
+		Interval: 100 * time.Millisecond,
+		MD5Sum:   "deadbeef",
+	}
+
+	if diff := deep.Equal(h, expect); diff != nil {
+		t.Fatalf("unexpected header: %s", diff)
+	}
+}
+
+func TestParseStringBytes(t *testing.T) {
+	const input = "V:1;F:v1,v2;#0,1;20,0;"
+	expect := Points{{0, 1}, {20, 0}}
+
+	p, err := ParseString(input)
+	if err != nil {
+		t.Fatal("cannot parse string:", err)
+	}
+	if diff := deep.Equal(p.Points, expect); diff != nil {
+		t.Errorf("unexpected string points: %s", diff)
+	}
+
+	p, err = ParseBytes([]byte(input))
+	if err != nil {
+		t.Fatal("cannot parse bytes:", err)
+	}
+	if diff := deep.Equal(p.Points, expect); diff != nil {
+		t.Errorf("unexpected bytes points: %s", diff)
+	}
+}
+
+func TestParseBOM(t *testing.T) {
+	for _, name := range []string{"testdata/edge", "testdata/v0"} {
+		t.Run(name, func(t *testing.T) {
+			expect, err := Parse(openFile(t, name))
+			if err != nil {
+				t.Fatalf("cannot parse %s: %v", name, err)
+			}
+
+			p, err := Parse(openFile(t, name+"-bom"))
+			if err != nil {
+				t.Fatalf("cannot parse %s-bom: %v", name, err)
+			}
+
+			if diff := deep.Equal(p, expect); diff != nil {
+				t.Fatalf("unexpected pattern: %s", diff)
+			}
+		})
+	}
+}
+
+func TestParseMissingDelimiter(t *testing.T) {
+	expect, err := Parse(openFile(t, "testdata/edge"))
+	if err != nil {
+		t.Fatal("cannot parse testdata/edge:", err)
+	}
+
+	p, err := Parse(openFile(t, "testdata/edge-nohash"))
+	if err != nil {
+		t.Fatal("cannot parse testdata/edge-nohash:", err)
+	}
+
+	if diff := deep.Equal(p, expect); diff != nil {
+		t.Fatalf("unexpected pattern: %s", diff)
+	}
+
+	r := NewReader(openFile(t, "testdata/edge-nohash"))
+	if err := r.SkipHeader(); err != nil {
+		t.Fatal("cannot skip header:", err)
+	}
+
+	points, err := r.ReadAllV1Points()
+	if err != nil {
+		t.Fatal("cannot read points after skipping header:", err)
+	}
+
+	if diff := deep.Equal(points, expect.Points); diff != nil {
+		t.Fatalf("unexpected points: %s", diff)
+	}
+}
+
+func TestParseNoFeatures(t *testing.T) {
+	h, err := ParseHeaderOnly(openFile(t, "testdata/edge-nofeatures"))
+	if err != nil {
+		t.Fatal("cannot parse header:", err)
+	}
+
+	if h.Features != nil {
+		t.Fatalf("expected no features in header, got %v", h.Features)
+	}
+
+	tests := []struct {
+		name   string
+		input  io.Reader
+		expect []Feature
+		points Points
+	}{
+		{"empty", openFile(t, "testdata/edge-nofeatures"), []Feature{Vibrate1, Vibrate2}, Points{{0, 1}, {1, 0}, {20, 20}}},
+		{"absent", strings.NewReader("V:1;S:100;#0;1;"), []Feature{Vibrate}, Points{{0}, {1}}},
+		{"flat", strings.NewReader("V:1;S:100;#0,5,10,20,"), []Feature{Vibrate}, Points{{0}, {5}, {10}, {20}}},
+		{"v0", strings.NewReader("0,1,"), []Feature{Vibrate}, Points{{0}, {1}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := Parse(test.input)
+			if err != nil {
+				t.Fatal("cannot parse pattern:", err)
+			}
+
+			if diff := deep.Equal(p.Features, test.expect); diff != nil {
+				t.Fatalf("unexpected features: %s", diff)
+			}
+
+			if diff := deep.Equal(p.Points, test.points); diff != nil {
+				t.Fatalf("unexpected points: %s", diff)
+			}
+		})
+	}
+}
+
+func TestParseMissingDelimiterSameLine(t *testing.T) {
+	_, err := ParseString("V:1;T:Edge;F:v;S:100;1;2;3;\n")
+	if err == nil {
+		t.Fatal("expected error parsing points on the header line")
+	}
+
+	b := bufio.NewReader(strings.NewReader("V:1;T:Edge;F:v;S:100;\n1;2;"))
+	p, err := Parse(b)
+	if err != nil {
+		t.Fatal("cannot parse pattern:", err)
+	}
+	if diff := deep.Equal(p.Points, Points{{1}, {2}}); diff != nil {
+		t.Fatalf("unexpected points: %s", diff)
+	}
+
+	// The caller's reader must not have been reset onto the remainder.
+	if rest, _ := io.ReadAll(b); len(rest) != 0 {
+		t.Fatalf("caller's reader was reset, still has %q", rest)
+	}
+}
+
+func TestParseStreaming(t *testing.T) {
+	readers := map[string]func(io.Reader) io.Reader{
+		"one byte": iotest.OneByteReader,
+		"half":     iotest.HalfReader,
+		"data err": iotest.DataErrReader,
+	}
+
+	for _, name := range []string{"testdata/edge", "testdata/v0"} {
+		expect, err := Parse(openFile(t, name))
+		if err != nil {
+			t.Fatalf("cannot parse %s: %v", name, err)
+		}
+
+		for readerName, newReader := range readers {
+			t.Run(name+"/"+readerName, func(t *testing.T) {
+				// Use the smallest buffer possible to ensure that nothing
+				// relies on the whole file being buffered.
+				r := bufio.NewReaderSize(newReader(openFile(t, name)), 16)
+
+				p, err := Parse(r)
+				if err != nil {
+					t.Fatal("cannot parse:", err)
+				}
+
+				if diff := deep.Equal(p, expect); diff != nil {
+					t.Fatalf("unexpected pattern: %s", diff)
+				}
+			})
+		}
+	}
+}
+
 func openFile(t *testing.T, name string) io.Reader {
 	f, err := os.Open(name)
 	if err != nil {
@@ -110,3 +478,241 @@ func openFile(t *testing.T, name string) io.Reader {
 
 	return f
 }
+
+func FuzzReadHeader(f *testing.F) {
+	f.Add([]byte("V:1;T:Edge;F:v1,v2;S:100;M:deadbeef;#0,0;"))
+	f.Add([]byte("V:1;#"))
+	f.Add([]byte("V:;:;F:;S:;#"))
+	f.Add([]byte("0,0,1,2,"))
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		r := NewReader(bytes.NewReader(b))
+		r.ReadHeader()
+	})
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add([]byte("V:1;T:Edge;F:v1,v2;S:100;M:deadbeef;#\n0,1;1,0;20,20;;\n"))
+	f.Add([]byte("V:1;F:v1,v2;#0,1;1"))
+	f.Add([]byte("V:1;F:v;#1;2,3;4"))
+	f.Add([]byte("\n0,0,8,7,100,\n"))
+	f.Add([]byte("0,,1, 2 ,"))
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		p, err := Parse(bytes.NewReader(b))
+		if err != nil {
+			return
+		}
+
+		for i, point := range p.Points {
+			if len(point) != len(p.Features) {
+				t.Fatalf("point %d has %d motors, expected %d", i, len(point), len(p.Features))
+			}
+		}
+	})
+}
+
+func TestParseV1MismatchedStride(t *testing.T) {
+	inputs := []string{
+		"V:1;F:v1,v2;#0,1;1",
+		"V:1;F:v1,v2;#0,1;1,2,3;",
+	}
+
+	for _, input := range inputs {
+		if _, err := Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("expected error parsing %q", input)
+		}
+	}
+}
+
+func TestParseV1Truncated(t *testing.T) {
+	_, err := Parse(openFile(t, "testdata/edge-truncated"))
+	if !errors.Is(err, ErrTruncatedPoints) {
+		t.Fatalf("expected ErrTruncatedPoints, got %v", err)
+	}
+
+	_, err = ParseString("V:1;F:v1,v2;#0,1;2")
+	if !errors.Is(err, ErrTruncatedPoints) {
+		t.Fatalf("expected ErrTruncatedPoints, got %v", err)
+	}
+}
+
+func TestParseV1InvalidStride(t *testing.T) {
+	_, err := Parse(strings.NewReader("V:1;F:v1,v2;#\n,;0,1;1,0;"))
+	if !errors.Is(err, ErrInvalidStride) {
+		t.Fatalf("expected ErrInvalidStride, got %v", err)
+	}
+}
+
+func TestReaderSkipHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		expect string
+	}{
+		{"v1", "V:1;T:Edge;F:v1,v2;S:100;#0,1;1,0;", "0,1;1,0;"},
+		{"v0", "0,0,8,7,", "0,0,8,7,"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Use a buffer smaller than the header to ensure that it's still
+			// fully skipped.
+			r := NewReader(bufio.NewReaderSize(strings.NewReader(test.input), 16))
+			if err := r.SkipHeader(); err != nil {
+				t.Fatal("cannot skip header:", err)
+			}
+
+			rest, err := io.ReadAll(r.buf)
+			if err != nil {
+				t.Fatal("cannot read rest:", err)
+			}
+			if string(rest) != test.expect {
+				t.Fatalf("expected %q, got %q", test.expect, rest)
+			}
+		})
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	const input = "V:2;F:v1,v2;#0,1;20,0;"
+
+	if _, err := Parse(strings.NewReader(input)); err == nil {
+		t.Fatal("expected error parsing unknown version")
+	}
+
+	p, err := ParseLenient(strings.NewReader(input))
+	if err != nil {
+		t.Fatal("cannot parse leniently:", err)
+	}
+
+	if p.Version != 2 {
+		t.Errorf("expected declared version 2, got %d", p.Version)
+	}
+
+	if diff := deep.Equal(p.Points, Points{{0, 1}, {20, 0}}); diff != nil {
+		t.Fatalf("unexpected points: %s", diff)
+	}
+}
+
+func TestParseSentinelErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect error
+	}{
+		{"", ErrEmptyPattern},
+		{"V:3;F:v;#0;1;", ErrUnknownVersion},
+		{"V:1;F:v;#0,1;1,0;", ErrFeatureMismatch},
+		{"V:1;F:v;#,;", ErrInvalidStride},
+		{"V:1;F:v;S:0;#0;", ErrMalformed},
+		{"V:1;F:v;S:100;1;2;", ErrMalformed},
+		{"V:1;F:v1,v2;#0,1;2;3,4;", ErrMalformed},
+		{"V:1;F:v1,v2;#0,1;2,3,4;", ErrMalformed},
+	}
+
+	for _, test := range tests {
+		_, err := ParseString(test.input)
+		if !errors.Is(err, test.expect) {
+			t.Errorf("%q: expected %v, got %v", test.input, test.expect, err)
+		}
+	}
+}
+
+func TestParseFeatureMismatch(t *testing.T) {
+	_, err := Parse(strings.NewReader("V:1;T:Edge;F:v;#0,1;1,0;"))
+
+	var mismatchErr *FeatureMismatchError
+	if !errors.As(err, &mismatchErr) {
+		t.Fatalf("expected *FeatureMismatchError, got %v", err)
+	}
+
+	expect := &FeatureMismatchError{Type: "Edge", Declared: 1, Actual: 2}
+	if diff := deep.Equal(mismatchErr, expect); diff != nil {
+		t.Fatalf("unexpected error: %s", diff)
+	}
+}
+
+func TestReadHeaderExtra(t *testing.T) {
+	h, err := NewReader(strings.NewReader("V:1;T:Edge;C:red;X:1;#")).ReadHeader()
+	if err != nil {
+		t.Fatal("cannot read header:", err)
+	}
+
+	expect := map[string]string{"C": "red", "X": "1"}
+	if diff := deep.Equal(h.Extra, expect); diff != nil {
+		t.Fatalf("unexpected extra fields: %s", diff)
+	}
+}
+
+func TestReadHeaderInterval(t *testing.T) {
+	tests := []struct {
+		input string
+		valid bool
+	}{
+		{"V:1;S:1;#", true},
+		{"V:1;S:60000;#", true},
+		{"V:1;S:0;#", false},
+		{"V:1;S:-100;#", false},
+		{"V:1;S:60001;#", false},
+		{"V:1;S:9223372036854775807;#", false},
+	}
+
+	for _, test := range tests {
+		_, err := NewReader(strings.NewReader(test.input)).ReadHeader()
+		if (err == nil) != test.valid {
+			t.Errorf("%q: expected valid = %v, got error %v", test.input, test.valid, err)
+		}
+	}
+}
+
+func TestHeaderEffectiveInterval(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		expect   time.Duration
+	}{
+		{200 * time.Millisecond, 200 * time.Millisecond},
+		{0, DefaultInterval},
+		{-time.Second, DefaultInterval},
+	}
+
+	for _, test := range tests {
+		h := Header{Interval: test.interval}
+		if got := h.EffectiveInterval(); got != test.expect {
+			t.Errorf("%v: expected %v, got %v", test.interval, test.expect, got)
+		}
+	}
+}
+
+func TestHeaderNormalizedType(t *testing.T) {
+	tests := []struct {
+		typ    string
+		expect string
+	}{
+		{"Edge", "Edge"},
+		{"edge", "Edge"},
+		{"EDGE", "Edge"},
+		{"unknown", "unknown"},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		h := Header{Type: test.typ}
+		if got := h.NormalizedType(); got != test.expect {
+			t.Errorf("%q: expected %q, got %q", test.typ, test.expect, got)
+		}
+	}
+}
+
+func BenchmarkReadAllV0Points(b *testing.B) {
+	input := bytes.Repeat([]byte("0,8,100,7,3,"), 10000)
+
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		r := NewReader(bytes.NewReader(input))
+		if _, err := r.ReadAllV0Points(); err != nil {
+			b.Fatal("cannot read points:", err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package pattern
+
+import (
+	"context"
+	"time"
+)
+
+// Clock creates tickers for Play. It can be replaced in tests to advance the
+// playback deterministically instead of waiting in real time.
+type Clock interface {
+	// NewTicker returns a channel that receives every d and a function that
+	// stops the ticker.
+	NewTicker(d time.Duration) (c <-chan time.Time, stop func())
+}
+
+// RealClock is the Clock backed by time.NewTicker.
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) NewTicker(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTicker(d)
+	return t.C, t.Stop
+}
+
+// Play calls fn with each point in order, one every EffectiveInterval. The
+// first point is played immediately. It blocks until all points are played or
+// until ctx is done, in which case ctx's error is returned.
+func (p *Pattern) Play(ctx context.Context, fn func(i int, point Point)) error {
+	return p.PlayClock(ctx, RealClock, fn)
+}
+
+// PlayClock is like Play, except the ticker is created from the given Clock.
+func (p *Pattern) PlayClock(ctx context.Context, clock Clock, fn func(i int, point Point)) error {
+	if len(p.Points) == 0 {
+		return nil
+	}
+
+	tick, stop := clock.NewTicker(p.EffectiveInterval())
+	defer stop()
+
+	for i, point := range p.Points {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-tick:
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		fn(i, point)
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package pattern
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+type fakeClock struct {
+	interval time.Duration
+	c        chan time.Time
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) (<-chan time.Time, func()) {
+	c.interval = d
+	return c.c, func() {}
+}
+
+func TestPatternPlayClock(t *testing.T) {
+	p := &Pattern{
+		Header: Header{Interval: 200 * time.Millisecond},
+		Points: Points{{1}, {2}, {3}},
+	}
+
+	clock := &fakeClock{c: make(chan time.Time)}
+	played := make(chan Point)
+	done := make(chan error)
+
+	go func() {
+		done <- p.PlayClock(context.Background(), clock, func(i int, point Point) {
+			played <- point
+		})
+	}()
+
+	var got Points
+	got = append(got, <-played)
+
+	for i := 1; i < len(p.Points); i++ {
+		select {
+		case point := <-played:
+			t.Fatalf("point %v played before the ticker ticked", point)
+		case clock.c <- time.Time{}:
+		}
+		got = append(got, <-played)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal("cannot play:", err)
+	}
+
+	if clock.interval != p.Interval {
+		t.Errorf("expected ticker interval %v, got %v", p.Interval, clock.interval)
+	}
+
+	if diff := deep.Equal(got, p.Points); diff != nil {
+		t.Fatalf("unexpected played points: %s", diff)
+	}
+}
+
+func TestPatternPlayClockCancel(t *testing.T) {
+	p := &Pattern{Points: Points{{1}, {2}}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var played int
+	err := p.PlayClock(ctx, &fakeClock{c: make(chan time.Time)}, func(int, Point) {
+		played++
+		cancel()
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if played != 1 {
+		t.Fatalf("expected 1 point played, got %d", played)
+	}
+}
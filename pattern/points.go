@@ -0,0 +1,854 @@
+package pattern
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// ObservedMax returns the highest strength across all motors of all points. It
+// returns 0 if there are no points.
+//
+// This is useful for guessing the scale of a pattern whose declared version
+// can't be trusted: a maximum above 20 cannot be a version 1 strength, so the
+// data is likely in version 0's [0, 100] scale.
+func (p Points) ObservedMax() Strength {
+	var max Strength
+	for _, point := range p {
+		for _, s := range point {
+			if s > max {
+				max = s
+			}
+		}
+	}
+	return max
+}
+
+// GuessVersion guesses which version's scale the points are in, for pattern
+// files without a version header. This is only a heuristic:
+//
+//   - Points with more than one motor are V1, since V0 only has one.
+//   - A strength above 20 is V0, since it's outside of V1's scale.
+//   - Otherwise, the points fit both scales. V1 is guessed, since a V0
+//     pattern that never goes above a fifth of its scale is unlikely.
+//
+// V0 is returned if there are no points, which is what ReadHeader assumes for
+// headerless files.
+func GuessVersion(p Points) Version {
+	switch {
+	case len(p) == 0:
+		return V0
+	case p.Stride() > 1:
+		return V1
+	case p.ObservedMax() > 20:
+		return V0
+	default:
+		return V1
+	}
+}
+
+// NonSilentRanges returns the ranges of points in which at least one motor has
+// a strength above 0. Each range is [start, end), so p[start:end] are the
+// points in that range. Adjacent active points are merged into one range.
+func (p Points) NonSilentRanges() [][2]int {
+	var ranges [][2]int
+	start := -1
+
+	for i, point := range p {
+		if point.silent() {
+			if start != -1 {
+				ranges = append(ranges, [2]int{start, i})
+				start = -1
+			}
+			continue
+		}
+
+		if start == -1 {
+			start = i
+		}
+	}
+
+	if start != -1 {
+		ranges = append(ranges, [2]int{start, len(p)})
+	}
+
+	return ranges
+}
+
+func (p Point) silent() bool {
+	for _, s := range p {
+		if s > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Map returns a copy of the points with fn applied to every strength of every
+// motor. p is not modified.
+func (p Points) Map(fn func(Strength) Strength) Points {
+	var total int
+	for _, point := range p {
+		total += len(point)
+	}
+
+	// Share a single backing array between all mapped points.
+	backing := make([]Strength, 0, total)
+	mapped := make(Points, len(p))
+
+	for i, point := range p {
+		head := len(backing)
+		for _, s := range point {
+			backing = append(backing, fn(s))
+		}
+		mapped[i] = backing[head:len(backing):len(backing)]
+	}
+
+	return mapped
+}
+
+// Stride returns the number of motors in each point. Points returned by Parse
+// are guaranteed to all have the same stride, so only the first point is
+// checked. It returns 0 if there are no points.
+func (p Points) Stride() int {
+	if len(p) == 0 {
+		return 0
+	}
+	return len(p[0])
+}
+
+// At returns the i-th point and true, or false if i is out of range. Negative
+// indices count from the end, so -1 is the last point.
+func (p Points) At(i int) (Point, bool) {
+	if i < 0 {
+		i += len(p)
+	}
+	if i < 0 || i >= len(p) {
+		return nil, false
+	}
+	return p[i], true
+}
+
+// AtClamped returns the i-th point, where i is clamped to [0, len(p)-1]. This
+// is useful for reading the neighbors of a point near either end. It returns
+// nil if there are no points.
+func (p Points) AtClamped(i int) Point {
+	if len(p) == 0 {
+		return nil
+	}
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(p) {
+		i = len(p) - 1
+	}
+	return p[i]
+}
+
+// SplitEvery splits the points into consecutive chunks of at most n points
+// each; only the last chunk may be shorter. The chunks share p's backing array
+// but are capped, so appending to one never overwrites the next. It panics if n
+// is not positive.
+func (p Points) SplitEvery(n int) []Points {
+	if n <= 0 {
+		panic("SplitEvery: n must be positive")
+	}
+
+	chunks := make([]Points, 0, (len(p)+n-1)/n)
+	for h := 0; h < len(p); h += n {
+		t := h + n
+		if t > len(p) {
+			t = len(p)
+		}
+		chunks = append(chunks, p[h:t:t])
+	}
+
+	return chunks
+}
+
+// ByFeature is like Motors, except the motors are keyed by their features in
+// the header. It errors out if the number of features doesn't match the number
+// of motors, or if a feature is declared more than once.
+func (p *Pattern) ByFeature() (map[Feature]Points, error) {
+	if stride := p.Points.Stride(); stride > 0 && stride != len(p.Features) {
+		return nil, &FeatureMismatchError{
+			Type:     p.Type,
+			Declared: len(p.Features),
+			Actual:   stride,
+		}
+	}
+
+	motors := p.Motors()
+	features := make(map[Feature]Points, len(p.Features))
+
+	for i, f := range p.Features {
+		if _, ok := features[f]; ok {
+			return nil, fmt.Errorf("duplicate feature %q", f)
+		}
+
+		if motors == nil {
+			features[f] = Points{}
+			continue
+		}
+
+		features[f] = motors[i]
+	}
+
+	return features, nil
+}
+
+// ForEachMotor calls fn for each motor with that motor's strengths across all
+// points. values is a copy, so fn may keep or modify it without affecting p.
+func (p Points) ForEachMotor(fn func(motor int, values []Strength)) {
+	stride := p.Stride()
+
+	for motor := 0; motor < stride; motor++ {
+		values := make([]Strength, len(p))
+		for i, point := range p {
+			values[i] = point[motor]
+		}
+
+		fn(motor, values)
+	}
+}
+
+// AppendPoint appends a copy of the given point. The point must have as many
+// motors as the existing points; if there are none, it sets the stride for the
+// points appended after it.
+func (p *Pattern) AppendPoint(point Point) error {
+	if len(point) == 0 {
+		return fmt.Errorf("%w: point has no motors", ErrInvalidStride)
+	}
+
+	if stride := p.Points.Stride(); stride > 0 && len(point) != stride {
+		return fmt.Errorf(
+			"%w: point %d has %d motors, expected %d",
+			ErrInvalidStride, len(p.Points), len(point), stride,
+		)
+	}
+
+	p.Points = append(p.Points, append(Point(nil), point...))
+	return nil
+}
+
+// ActiveMotors returns the indices of the motors that have a strength above 0
+// in any point. Motors that are declared but never driven are left out.
+func (p *Pattern) ActiveMotors() []int {
+	var active []int
+
+	p.Points.ForEachMotor(func(motor int, values []Strength) {
+		for _, s := range values {
+			if s > 0 {
+				active = append(active, motor)
+				return
+			}
+		}
+	})
+
+	return active
+}
+
+// Motors splits the pattern's points into one Points per motor, such that the
+// i-th element contains only the strengths of motor i. Each returned point has
+// a length of 1.
+func (p *Pattern) Motors() []Points {
+	stride := p.Points.Stride()
+	if stride == 0 {
+		return nil
+	}
+
+	motors := make([]Points, stride)
+	for i := range motors {
+		backing := make([]Strength, len(p.Points))
+		points := make(Points, len(p.Points))
+
+		for j, point := range p.Points {
+			backing[j] = point[i]
+			points[j] = backing[j : j+1 : j+1]
+		}
+
+		motors[i] = points
+	}
+
+	return motors
+}
+
+// PointsEquivalent returns true if a and b describe the same motion once their
+// strengths are scaled to [0.0, 1.0] according to their versions. Each
+// strength may differ by at most tolerance. Points with differing counts or
+// strides are never equivalent.
+//
+// This is useful for comparing a version 0 pattern against a version 1 one,
+// since their raw strengths are on different scales.
+func PointsEquivalent(a Points, av Version, b Points, bv Version, tolerance float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if len(a[i]) != len(b[i]) {
+			return false
+		}
+
+		for j := range a[i] {
+			if math.Abs(a[i][j].Scale(av)-b[i][j].Scale(bv)) > tolerance {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// ScaledEqual is PointsEquivalent as a method, where p is scaled according to
+// v and other according to ov.
+func (p Points) ScaledEqual(v Version, other Points, ov Version, tolerance float64) bool {
+	return PointsEquivalent(p, v, other, ov, tolerance)
+}
+
+// TimeSeries returns the elapsed time at each point and the scaled strength of
+// the given motor at that point, in parallel slices. This is the shape that
+// most plotting libraries expect.
+func (p *Pattern) TimeSeries(motor int) (times []time.Duration, values []float64, err error) {
+	if stride := p.Points.Stride(); motor < 0 || motor >= stride {
+		return nil, nil, fmt.Errorf("motor %d out of range [0, %d)", motor, stride)
+	}
+
+	interval := p.EffectiveInterval()
+
+	times = make([]time.Duration, len(p.Points))
+	values = make([]float64, len(p.Points))
+
+	for i, point := range p.Points {
+		times[i] = time.Duration(i) * interval
+		values[i] = point[motor].Scale(p.Version)
+	}
+
+	return times, values, nil
+}
+
+// ScaledPoints returns all points scaled into floats within range [0.0, 1.0]
+// according to the pattern's version.
+func (p *Pattern) ScaledPoints() [][]float64 {
+	var total int
+	for _, point := range p.Points {
+		total += len(point)
+	}
+
+	// Share a single backing array between all scaled points.
+	backing := make([]float64, total)
+	scaled := make([][]float64, len(p.Points))
+
+	var head int
+	for i, point := range p.Points {
+		tail := head + len(point)
+		scaled[i] = point.ScaleAppend(p.Version, backing[head:head:tail])
+		head = tail
+	}
+
+	return scaled
+}
+
+// Lerp linearly interpolates between p and to, where t is within [0.0, 1.0].
+// The strengths are rounded to the nearest integer. Both points must have the
+// same number of motors.
+func (p Point) Lerp(to Point, t float64) Point {
+	t = clampF(t)
+
+	lerped := make(Point, len(p))
+	for i := range p {
+		from := float64(p[i])
+		lerped[i] = Strength(math.Round(from + (float64(to[i])-from)*t))
+	}
+
+	return lerped
+}
+
+// Sample returns the point at the given elapsed time, interpolating between
+// the two points around it. Times before the first point or after the last
+// point return those points. Nil is returned if there are no points.
+func (p *Pattern) Sample(elapsed time.Duration) Point {
+	if len(p.Points) == 0 {
+		return nil
+	}
+
+	if elapsed <= 0 {
+		return append(Point(nil), p.Points[0]...)
+	}
+
+	interval := p.EffectiveInterval()
+
+	i := int(elapsed / interval)
+	if i >= len(p.Points)-1 {
+		return append(Point(nil), p.Points[len(p.Points)-1]...)
+	}
+
+	t := float64(elapsed%interval) / float64(interval)
+	return p.Points[i].Lerp(p.Points[i+1], t)
+}
+
+// maxStrength returns the maximum strength of the given version.
+func maxStrength(v Version) (Strength, error) {
+	switch v {
+	case V0:
+		return 100, nil
+	case V1:
+		return 20, nil
+	default:
+		return 0, fmt.Errorf("%w %d", ErrUnknownVersion, v)
+	}
+}
+
+// ToVersion returns a copy of the pattern converted to the given version, with
+// its strengths rescaled to the version's scale. Patterns with more than one
+// motor cannot be converted to version 0.
+func (p *Pattern) ToVersion(v Version) (*Pattern, error) {
+	srcMax, err := maxStrength(p.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	dstMax, err := maxStrength(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if v == V0 && p.Points.Stride() > 1 {
+		return nil, fmt.Errorf("v0 cannot have %d motors", p.Points.Stride())
+	}
+
+	converted := Pattern{Header: p.Header.clone()}
+	converted.Version = v
+
+	converted.Points = make(Points, len(p.Points))
+	for i, point := range p.Points {
+		converted.Points[i] = make(Point, len(point))
+		for j, s := range point {
+			scaled := math.Round(float64(s) * float64(dstMax) / float64(srcMax))
+			converted.Points[i][j] = Strength(math.Min(scaled, float64(dstMax)))
+		}
+	}
+
+	return &converted, nil
+}
+
+// clone returns a copy of h that doesn't share its Features or Extra.
+func (h Header) clone() Header {
+	h.Features = append([]Feature(nil), h.Features...)
+
+	if h.Extra != nil {
+		extra := make(map[string]string, len(h.Extra))
+		for k, v := range h.Extra {
+			extra[k] = v
+		}
+		h.Extra = extra
+	}
+
+	return h
+}
+
+// FitToDuration returns a copy of the pattern whose points are repeated from
+// the start, or cut short, so that it lasts as close to target as possible at
+// the same interval. Since the duration can only be a multiple of the
+// interval, the number of points is target divided by EffectiveInterval,
+// rounded to the nearest integer with halves rounded up; a target shorter than
+// half an interval yields no points. The returned points are shared with p. A
+// pattern without points is returned as a copy without points.
+func (p *Pattern) FitToDuration(target time.Duration) *Pattern {
+	fitted := Pattern{Header: p.Header.clone()}
+
+	if len(p.Points) == 0 || target <= 0 {
+		return &fitted
+	}
+
+	interval := p.EffectiveInterval()
+	n := int((target + interval/2) / interval)
+
+	fitted.Points = make(Points, n)
+	for i := range fitted.Points {
+		fitted.Points[i] = p.Points[i%len(p.Points)]
+	}
+
+	return &fitted
+}
+
+// PointRun is a point repeated Count times in a row.
+type PointRun struct {
+	Point Point
+	Count int
+}
+
+// RunLengthEncode compresses consecutive identical points into runs. The runs
+// share their points with p. Use DecodeRuns to get the points back.
+func (p Points) RunLengthEncode() []PointRun {
+	var runs []PointRun
+
+	for _, point := range p {
+		if len(runs) > 0 && runs[len(runs)-1].Point.Equal(point) {
+			runs[len(runs)-1].Count++
+			continue
+		}
+
+		runs = append(runs, PointRun{Point: point, Count: 1})
+	}
+
+	return runs
+}
+
+// DedupConsecutive is like RunLengthEncode, except the unique consecutive
+// points and their run lengths are returned as separate slices of the same
+// length. The points keep their stride and are shared with p.
+func (p Points) DedupConsecutive() (Points, []int) {
+	runs := p.RunLengthEncode()
+
+	points := make(Points, len(runs))
+	counts := make([]int, len(runs))
+
+	for i, run := range runs {
+		points[i] = run.Point
+		counts[i] = run.Count
+	}
+
+	return points, counts
+}
+
+// DecodeRuns expands the runs returned by RunLengthEncode back into points.
+// Points within the same run share the same backing array.
+func DecodeRuns(runs []PointRun) Points {
+	var total int
+	for _, run := range runs {
+		total += run.Count
+	}
+
+	points := make(Points, 0, total)
+	for _, run := range runs {
+		for i := 0; i < run.Count; i++ {
+			points = append(points, run.Point)
+		}
+	}
+
+	return points
+}
+
+// Equal returns true if p and other have the same number of motors and the
+// same strength for each motor.
+func (p Point) Equal(other Point) bool {
+	if len(p) != len(other) {
+		return false
+	}
+	for i := range p {
+		if p[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Energy returns the mean scaled strength across all motors of all points,
+// which is within [0.0, 1.0]. It returns 0 if there are no points. This is
+// useful for sorting patterns from gentle to intense.
+func (p *Pattern) Energy() float64 {
+	var sum float64
+	var n int
+
+	for _, point := range p.Points {
+		for _, s := range point {
+			sum += s.Scale(p.Version)
+			n++
+		}
+	}
+
+	if n == 0 {
+		return 0
+	}
+
+	return sum / float64(n)
+}
+
+// DominantFeature returns the feature whose motor has the highest total scaled
+// strength. ok is false if there are no points, if no motor is ever active, or
+// if the number of features doesn't match the number of motors.
+func (p *Pattern) DominantFeature() (f Feature, ok bool) {
+	if p.Points.Stride() != len(p.Features) {
+		return "", false
+	}
+
+	var max float64
+
+	p.Points.ForEachMotor(func(motor int, values []Strength) {
+		var energy float64
+		for _, s := range values {
+			energy += s.Scale(p.Version)
+		}
+
+		if energy > max {
+			max = energy
+			f = p.Features[motor]
+			ok = true
+		}
+	})
+
+	return f, ok
+}
+
+// Envelope returns, for each motor, an envelope that follows its strengths
+// scaled according to v. At each point, the envelope moves towards the
+// strength by a fraction of the distance: attack when rising and release when
+// falling, both clamped to [0.0, 1.0]. An attack of 1 rises instantly, and a
+// small release decays slowly. The envelope starts at the first strength. It
+// returns nil if there are no points.
+func (p Points) Envelope(v Version, attack, release float64) [][]float64 {
+	if len(p) == 0 {
+		return nil
+	}
+
+	attack = clampF(attack)
+	release = clampF(release)
+
+	stride := p.Stride()
+
+	// Share a single backing array between all motors.
+	backing := make([]float64, stride*len(p))
+	envelopes := make([][]float64, stride)
+
+	p.ForEachMotor(func(motor int, values []Strength) {
+		env := backing[motor*len(p) : (motor+1)*len(p) : (motor+1)*len(p)]
+		level := values[0].Scale(v)
+
+		for i, s := range values {
+			x := s.Scale(v)
+			if x > level {
+				level += (x - level) * attack
+			} else {
+				level += (x - level) * release
+			}
+			env[i] = level
+		}
+
+		envelopes[motor] = env
+	})
+
+	return envelopes
+}
+
+// Period estimates how many points it takes for the pattern to repeat, using
+// the autocorrelation of the mean strength across all motors, scaled according
+// to v. Only periods that repeat at least twice are considered. confidence is
+// within [0.0, 1.0], where 1 means that the points repeat exactly; it's 0,
+// along with points, if no periodicity is found at all.
+func (p Points) Period(v Version) (points int, confidence float64) {
+	n := len(p)
+	if n < 4 {
+		return 0, 0
+	}
+
+	x := make([]float64, n)
+	var mean float64
+	for i, point := range p {
+		for _, s := range point {
+			x[i] += s.Scale(v)
+		}
+		x[i] /= float64(len(point))
+		mean += x[i]
+	}
+	mean /= float64(n)
+
+	var variance float64
+	for i := range x {
+		x[i] -= mean
+		variance += x[i] * x[i]
+	}
+	variance /= float64(n)
+
+	if variance == 0 {
+		return 0, 0
+	}
+
+	// r[lag] is the autocorrelation at lag, normalized by the number of
+	// overlapping points so that longer lags aren't penalized.
+	maxLag := n / 2
+	r := make([]float64, maxLag+2)
+	r[0] = 1
+	for lag := 1; lag < len(r) && lag < n; lag++ {
+		var sum float64
+		for i := 0; i+lag < n; i++ {
+			sum += x[i] * x[i+lag]
+		}
+		r[lag] = sum / float64(n-lag) / variance
+	}
+
+	// Pick the highest peak; ties go to the shortest period, since its
+	// multiples correlate just as well.
+	for lag := 1; lag <= maxLag; lag++ {
+		if r[lag] > confidence && r[lag] >= r[lag-1] && r[lag] >= r[lag+1] {
+			points, confidence = lag, r[lag]
+		}
+	}
+
+	return points, clampF(confidence)
+}
+
+// DutyCycle returns, for each motor, the fraction of points whose strength
+// scaled according to v is above threshold. It returns nil if there are no
+// points.
+func (p Points) DutyCycle(v Version, threshold float64) []float64 {
+	if len(p) == 0 {
+		return nil
+	}
+
+	cycles := make([]float64, p.Stride())
+
+	p.ForEachMotor(func(motor int, values []Strength) {
+		var n int
+		for _, s := range values {
+			if s.Scale(v) > threshold {
+				n++
+			}
+		}
+		cycles[motor] = float64(n) / float64(len(values))
+	})
+
+	return cycles
+}
+
+// IntensityLoad returns a heuristic for how demanding playing the pattern is,
+// e.g. on a toy's battery. It integrates the scaled strengths of all motors
+// over time, then divides it by the pattern's duration. Unlike Energy, the
+// motors are summed rather than averaged, since each one draws power, so the
+// result is within [0.0, number of motors]. It returns 0 if there are no
+// points.
+func (p *Pattern) IntensityLoad() float64 {
+	if len(p.Points) == 0 || p.Interval <= 0 {
+		return 0
+	}
+
+	var integral float64
+	for _, point := range p.Points {
+		for _, s := range point {
+			integral += s.Scale(p.Version) * p.Interval.Seconds()
+		}
+	}
+
+	duration := time.Duration(len(p.Points)) * p.Interval
+	return integral / duration.Seconds()
+}
+
+// maxResampleSteps is the maximum number of steps that SimilarTo and ZipByTime
+// resample patterns into.
+const maxResampleSteps = 1 << 20
+
+// SimilarTo returns true if p and other play the same motion, even if they
+// have different intervals or versions. Both are resampled to the smaller of
+// the two intervals, then compared like PointsEquivalent. Patterns with
+// differing motor counts are never similar, and neither are patterns whose
+// intervals are so far apart that resampling would take more than about a
+// million steps.
+func (p *Pattern) SimilarTo(other *Pattern, tolerance float64) bool {
+	if p.Points.Stride() != other.Points.Stride() {
+		return false
+	}
+
+	if p.Interval <= 0 || other.Interval <= 0 {
+		return false
+	}
+
+	interval := p.Interval
+	if other.Interval < interval {
+		interval = other.Interval
+	}
+
+	for _, pattern := range []*Pattern{p, other} {
+		if time.Duration(len(pattern.Points))*pattern.Interval/interval > maxResampleSteps {
+			return false
+		}
+	}
+
+	return PointsEquivalent(
+		p.resampleHold(interval), p.Version,
+		other.resampleHold(interval), other.Version,
+		tolerance,
+	)
+}
+
+// resampleHold resamples the points to the given interval. Unlike Sample,
+// points aren't interpolated; each one is held for the whole interval, which is
+// how toys play them.
+func (p *Pattern) resampleHold(interval time.Duration) Points {
+	duration := time.Duration(len(p.Points)) * p.Interval
+
+	resampled := make(Points, duration/interval)
+	for i := range resampled {
+		resampled[i] = p.Points[time.Duration(i)*interval/p.Interval]
+	}
+
+	return resampled
+}
+
+// TimedPoints holds one point from each of several patterns at the same elapsed
+// time.
+type TimedPoints struct {
+	Elapsed time.Duration
+	Points  Points
+}
+
+// ZipByTime aligns the given patterns on a shared timeline, resampled to the
+// finest interval that all of their intervals are multiples of. Each point is
+// held for its pattern's whole interval. Patterns that end earlier than others
+// have nil points past their end. It errors out if a pattern has no interval,
+// or if the intervals are incompatible: the shared interval is below
+// MinInterval, or the timeline would have more than about a million steps.
+func ZipByTime(patterns ...*Pattern) ([]TimedPoints, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	var interval, duration time.Duration
+
+	for i, p := range patterns {
+		if p.Interval <= 0 {
+			return nil, fmt.Errorf("pattern %d has invalid interval %v", i, p.Interval)
+		}
+
+		interval = gcdDuration(interval, p.Interval)
+
+		if d := time.Duration(len(p.Points)) * p.Interval; d > duration {
+			duration = d
+		}
+	}
+
+	if interval < MinInterval {
+		return nil, fmt.Errorf("shared interval %v is below %v", interval, MinInterval)
+	}
+
+	steps := duration / interval
+	if steps > maxResampleSteps {
+		return nil, fmt.Errorf("timeline has %d steps, more than %d", steps, maxResampleSteps)
+	}
+
+	zipped := make([]TimedPoints, steps)
+
+	for i := range zipped {
+		elapsed := time.Duration(i) * interval
+
+		points := make(Points, len(patterns))
+		for j, p := range patterns {
+			if k := int(elapsed / p.Interval); k < len(p.Points) {
+				points[j] = p.Points[k]
+			}
+		}
+
+		zipped[i] = TimedPoints{
+			Elapsed: elapsed,
+			Points:  points,
+		}
+	}
+
+	return zipped, nil
+}
+
+func gcdDuration(a, b time.Duration) time.Duration {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
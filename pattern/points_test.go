@@ -0,0 +1,700 @@
+package pattern
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestPointsObservedMax(t *testing.T) {
+	tests := []struct {
+		name   string
+		points Points
+		expect Strength
+	}{
+		{"empty", nil, 0},
+		{"v0", Points{{0}, {8}, {100}, {3}}, 100},
+		{"v1", Points{{0, 1}, {20, 0}, {5, 19}}, 20},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if max := test.points.ObservedMax(); max != test.expect {
+				t.Errorf("expected %d, got %d", test.expect, max)
+			}
+		})
+	}
+}
+
+func TestGuessVersion(t *testing.T) {
+	tests := []struct {
+		name   string
+		points Points
+		expect Version
+	}{
+		{"empty", nil, V0},
+		{"multiple motors", Points{{50, 0}}, V1},
+		{"above 20", Points{{0}, {21}}, V0},
+		{"ambiguous", Points{{0}, {20}}, V1},
+	}
+
+	for _, test := range tests {
+		if v := GuessVersion(test.points); v != test.expect {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expect, v)
+		}
+	}
+}
+
+func TestPointsNonSilentRanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		points Points
+		expect [][2]int
+	}{
+		{"empty", nil, nil},
+		{"silent", Points{{0, 0}, {0, 0}}, nil},
+		{"active", Points{{1, 0}, {0, 1}}, [][2]int{{0, 2}}},
+		{"mixed", Points{{0, 0}, {1, 0}, {2, 2}, {0, 0}, {0, 0}, {0, 3}}, [][2]int{{1, 3}, {5, 6}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := deep.Equal(test.points.NonSilentRanges(), test.expect); diff != nil {
+				t.Fatalf("unexpected ranges: %s", diff)
+			}
+		})
+	}
+}
+
+func TestPointsMap(t *testing.T) {
+	p := Points{{0, 5}, {10, 20}}
+
+	inverted := p.Map(func(s Strength) Strength { return 20 - s })
+
+	if diff := deep.Equal(inverted, Points{{20, 15}, {10, 0}}); diff != nil {
+		t.Fatalf("unexpected mapped points: %s", diff)
+	}
+
+	if diff := deep.Equal(p, Points{{0, 5}, {10, 20}}); diff != nil {
+		t.Fatalf("Map modified the points: %s", diff)
+	}
+}
+
+func TestPointsAt(t *testing.T) {
+	p := Points{{0}, {1}, {2}}
+
+	tests := []struct {
+		i       int
+		point   Point
+		ok      bool
+		clamped Point
+	}{
+		{0, Point{0}, true, Point{0}},
+		{2, Point{2}, true, Point{2}},
+		{-1, Point{2}, true, Point{0}},
+		{-3, Point{0}, true, Point{0}},
+		{-4, nil, false, Point{0}},
+		{3, nil, false, Point{2}},
+	}
+
+	for _, test := range tests {
+		point, ok := p.At(test.i)
+		if ok != test.ok || !point.Equal(test.point) {
+			t.Errorf("At(%d): expected %v, %v, got %v, %v", test.i, test.point, test.ok, point, ok)
+		}
+
+		if clamped := p.AtClamped(test.i); !clamped.Equal(test.clamped) {
+			t.Errorf("AtClamped(%d): expected %v, got %v", test.i, test.clamped, clamped)
+		}
+	}
+
+	if point := (Points{}).AtClamped(0); point != nil {
+		t.Errorf("expected nil point, got %v", point)
+	}
+}
+
+func TestPointsSplitEvery(t *testing.T) {
+	p := Points{{0}, {1}, {2}, {3}, {4}}
+
+	chunks := p.SplitEvery(2)
+	if diff := deep.Equal(chunks, []Points{{{0}, {1}}, {{2}, {3}}, {{4}}}); diff != nil {
+		t.Fatalf("unexpected chunks: %s", diff)
+	}
+
+	_ = append(chunks[0], Point{9})
+	if diff := deep.Equal(chunks[1][0], Point{2}); diff != nil {
+		t.Fatalf("appending to a chunk overwrote the next one: %s", diff)
+	}
+
+	if chunks := (Points{}).SplitEvery(2); len(chunks) != 0 {
+		t.Fatalf("expected no chunks, got %v", chunks)
+	}
+}
+
+func TestPatternAppendPoint(t *testing.T) {
+	var p Pattern
+
+	if err := p.AppendPoint(Point{0, 1}); err != nil {
+		t.Fatal("cannot append first point:", err)
+	}
+	if err := p.AppendPoint(Point{2, 3}); err != nil {
+		t.Fatal("cannot append second point:", err)
+	}
+
+	if err := p.AppendPoint(Point{4}); !errors.Is(err, ErrInvalidStride) {
+		t.Errorf("expected ErrInvalidStride, got %v", err)
+	}
+	if err := p.AppendPoint(nil); !errors.Is(err, ErrInvalidStride) {
+		t.Errorf("expected ErrInvalidStride, got %v", err)
+	}
+
+	if diff := deep.Equal(p.Points, Points{{0, 1}, {2, 3}}); diff != nil {
+		t.Fatalf("unexpected points: %s", diff)
+	}
+}
+
+func TestPatternActiveMotors(t *testing.T) {
+	p := &Pattern{
+		Points: Points{{0, 0, 1}, {5, 0, 0}, {0, 0, 0}},
+	}
+
+	if diff := deep.Equal(p.ActiveMotors(), []int{0, 2}); diff != nil {
+		t.Fatalf("unexpected active motors: %s", diff)
+	}
+}
+
+func TestPatternMotors(t *testing.T) {
+	p := &Pattern{
+		Points: Points{{0, 1}, {2, 3}, {4, 5}},
+	}
+
+	expect := []Points{
+		{{0}, {2}, {4}},
+		{{1}, {3}, {5}},
+	}
+
+	if diff := deep.Equal(p.Motors(), expect); diff != nil {
+		t.Fatalf("unexpected motors: %s", diff)
+	}
+}
+
+func TestPatternByFeature(t *testing.T) {
+	p := &Pattern{
+		Header: Header{Features: []Feature{Vibrate, Rotate}},
+		Points: Points{{0, 1}, {2, 3}},
+	}
+
+	features, err := p.ByFeature()
+	if err != nil {
+		t.Fatal("cannot split by feature:", err)
+	}
+
+	expect := map[Feature]Points{
+		Vibrate: {{0}, {2}},
+		Rotate:  {{1}, {3}},
+	}
+	if diff := deep.Equal(features, expect); diff != nil {
+		t.Fatalf("unexpected features: %s", diff)
+	}
+
+	p.Features = []Feature{Vibrate}
+	if _, err := p.ByFeature(); !errors.Is(err, ErrFeatureMismatch) {
+		t.Errorf("expected ErrFeatureMismatch, got %v", err)
+	}
+
+	p.Features = []Feature{Vibrate, Vibrate}
+	if _, err := p.ByFeature(); err == nil {
+		t.Error("expected error for duplicate features")
+	}
+}
+
+func TestPointsEquivalent(t *testing.T) {
+	tests := []struct {
+		name   string
+		a      Points
+		av     Version
+		b      Points
+		bv     Version
+		expect bool
+	}{
+		{"same scale", Points{{0}, {5}}, V0, Points{{0}, {1}}, V1, true},
+		{"different", Points{{0}, {50}}, V0, Points{{0}, {1}}, V1, false},
+		{"different count", Points{{0}}, V0, Points{{0}, {0}}, V1, false},
+		{"different stride", Points{{0, 0}}, V1, Points{{0}}, V1, false},
+		{"within tolerance", Points{{6}}, V0, Points{{1}}, V1, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			eq := PointsEquivalent(test.a, test.av, test.b, test.bv, 0.01)
+			if eq != test.expect {
+				t.Errorf("expected %v, got %v", test.expect, eq)
+			}
+
+			if eq := test.a.ScaledEqual(test.av, test.b, test.bv, 0.01); eq != test.expect {
+				t.Errorf("ScaledEqual: expected %v, got %v", test.expect, eq)
+			}
+		})
+	}
+}
+
+func TestPointsForEachMotor(t *testing.T) {
+	p := Points{{0, 1}, {2, 3}, {4, 5}}
+
+	var motors [][]Strength
+	p.ForEachMotor(func(motor int, values []Strength) {
+		if motor != len(motors) {
+			t.Errorf("expected motor %d, got %d", len(motors), motor)
+		}
+		motors = append(motors, values)
+		values[0] = 100
+	})
+
+	expect := [][]Strength{{100, 2, 4}, {100, 3, 5}}
+	if diff := deep.Equal(motors, expect); diff != nil {
+		t.Fatalf("unexpected values: %s", diff)
+	}
+
+	if p[0][0] != 0 || p[0][1] != 1 {
+		t.Fatalf("ForEachMotor modified the points: %v", p)
+	}
+}
+
+func TestPatternTimeSeries(t *testing.T) {
+	p := &Pattern{
+		Header: Header{
+			Version:  V1,
+			Interval: 100 * time.Millisecond,
+		},
+		Points: Points{{0, 20}, {10, 5}, {20, 0}},
+	}
+
+	times, values, err := p.TimeSeries(0)
+	if err != nil {
+		t.Fatal("cannot get time series:", err)
+	}
+
+	expectTimes := []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond}
+	if diff := deep.Equal(times, expectTimes); diff != nil {
+		t.Errorf("unexpected times: %s", diff)
+	}
+
+	expectValues := []float64{0, 0.5, 1}
+	if diff := deep.Equal(values, expectValues); diff != nil {
+		t.Errorf("unexpected values: %s", diff)
+	}
+
+	if _, _, err := p.TimeSeries(2); err == nil {
+		t.Error("expected error for out of range motor")
+	}
+}
+
+func TestPatternScaledPoints(t *testing.T) {
+	p := &Pattern{
+		Header: Header{Version: V0},
+		Points: Points{{0}, {50}, {100}},
+	}
+
+	expect := [][]float64{{0}, {0.5}, {1}}
+	if diff := deep.Equal(p.ScaledPoints(), expect); diff != nil {
+		t.Fatalf("unexpected scaled points: %s", diff)
+	}
+}
+
+func TestPatternSample(t *testing.T) {
+	p := &Pattern{
+		Header: Header{Interval: 100 * time.Millisecond},
+		Points: Points{{0, 20}, {10, 0}, {20, 20}},
+	}
+
+	tests := []struct {
+		elapsed time.Duration
+		expect  Point
+	}{
+		{-time.Second, Point{0, 20}},
+		{0, Point{0, 20}},
+		{50 * time.Millisecond, Point{5, 10}},
+		{100 * time.Millisecond, Point{10, 0}},
+		{175 * time.Millisecond, Point{18, 15}},
+		{time.Second, Point{20, 20}},
+	}
+
+	for _, test := range tests {
+		if diff := deep.Equal(p.Sample(test.elapsed), test.expect); diff != nil {
+			t.Errorf("at %v: unexpected point: %s", test.elapsed, diff)
+		}
+	}
+}
+
+func TestPatternToVersion(t *testing.T) {
+	v0 := &Pattern{
+		Header: Header{Version: V0, Features: []Feature{Vibrate}},
+		Points: Points{{0}, {50}, {100}, {255}},
+	}
+
+	v1, err := v0.ToVersion(V1)
+	if err != nil {
+		t.Fatal("cannot convert to v1:", err)
+	}
+
+	if v1.Version != V1 {
+		t.Errorf("expected version 1, got %d", v1.Version)
+	}
+	if diff := deep.Equal(v1.Points, Points{{0}, {10}, {20}, {20}}); diff != nil {
+		t.Errorf("unexpected v1 points: %s", diff)
+	}
+
+	back, err := v1.ToVersion(V0)
+	if err != nil {
+		t.Fatal("cannot convert back to v0:", err)
+	}
+	if diff := deep.Equal(back.Points, Points{{0}, {50}, {100}, {100}}); diff != nil {
+		t.Errorf("unexpected v0 points: %s", diff)
+	}
+
+	multi := &Pattern{
+		Header: Header{Version: V1, Features: []Feature{Vibrate1, Vibrate2}},
+		Points: Points{{0, 1}},
+	}
+	if _, err := multi.ToVersion(V0); err == nil {
+		t.Error("expected error converting multiple motors to v0")
+	}
+	if _, err := multi.ToVersion(2); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("expected ErrUnknownVersion, got %v", err)
+	}
+}
+
+func TestPointEqual(t *testing.T) {
+	tests := []struct {
+		a, b  Point
+		equal bool
+	}{
+		{Point{1, 2}, Point{1, 2}, true},
+		{Point{1, 2}, Point{1, 3}, false},
+		{Point{1, 2}, Point{1}, false},
+		{Point{}, nil, true},
+	}
+
+	for _, test := range tests {
+		if equal := test.a.Equal(test.b); equal != test.equal {
+			t.Errorf("%v.Equal(%v): expected %v, got %v", test.a, test.b, test.equal, equal)
+		}
+	}
+}
+
+func TestPatternFitToDuration(t *testing.T) {
+	p := &Pattern{
+		Header: Header{Features: []Feature{Vibrate}, Interval: 100 * time.Millisecond},
+		Points: Points{{1}, {2}, {3}},
+	}
+
+	tests := []struct {
+		target time.Duration
+		expect Points
+	}{
+		{700 * time.Millisecond, Points{{1}, {2}, {3}, {1}, {2}, {3}, {1}}},
+		{200 * time.Millisecond, Points{{1}, {2}}},
+		{249 * time.Millisecond, Points{{1}, {2}}},
+		{250 * time.Millisecond, Points{{1}, {2}, {3}}},
+		{49 * time.Millisecond, Points{}},
+	}
+
+	for _, test := range tests {
+		fitted := p.FitToDuration(test.target)
+		if diff := deep.Equal(fitted.Points, test.expect); diff != nil {
+			t.Errorf("%v: unexpected points: %s", test.target, diff)
+		}
+		if fitted.Interval != p.Interval {
+			t.Errorf("%v: interval changed to %v", test.target, fitted.Interval)
+		}
+	}
+
+	fitted := p.FitToDuration(time.Second)
+	fitted.Features[0] = Rotate
+	if p.Features[0] != Vibrate {
+		t.Fatal("FitToDuration shared the features")
+	}
+}
+
+func TestPointsRunLengthEncode(t *testing.T) {
+	p := Points{{0, 0}, {0, 0}, {0, 0}, {1, 2}, {0, 0}, {5, 5}, {5, 5}}
+
+	runs := p.RunLengthEncode()
+
+	expect := []PointRun{
+		{Point{0, 0}, 3},
+		{Point{1, 2}, 1},
+		{Point{0, 0}, 1},
+		{Point{5, 5}, 2},
+	}
+	if diff := deep.Equal(runs, expect); diff != nil {
+		t.Fatalf("unexpected runs: %s", diff)
+	}
+
+	if diff := deep.Equal(DecodeRuns(runs), p); diff != nil {
+		t.Fatalf("round-trip mismatch: %s", diff)
+	}
+}
+
+func TestPatternEnergy(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern *Pattern
+		expect  float64
+	}{
+		{"empty", &Pattern{}, 0},
+		{"v0", &Pattern{Header: Header{Version: V0}, Points: Points{{0}, {100}}}, 0.5},
+		{"v1", &Pattern{Header: Header{Version: V1}, Points: Points{{20, 20}, {10, 0}}}, 0.625},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if e := test.pattern.Energy(); e != test.expect {
+				t.Errorf("expected %v, got %v", test.expect, e)
+			}
+		})
+	}
+}
+
+func TestPointsDedupConsecutive(t *testing.T) {
+	p := Points{{0, 0}, {0, 0}, {1, 2}, {1, 2}, {1, 2}, {0, 0}}
+
+	points, counts := p.DedupConsecutive()
+
+	if diff := deep.Equal(points, Points{{0, 0}, {1, 2}, {0, 0}}); diff != nil {
+		t.Errorf("unexpected points: %s", diff)
+	}
+	if diff := deep.Equal(counts, []int{2, 3, 1}); diff != nil {
+		t.Errorf("unexpected counts: %s", diff)
+	}
+}
+
+func TestPatternDominantFeature(t *testing.T) {
+	p := &Pattern{
+		Header: Header{Version: V1, Features: []Feature{Vibrate, Rotate}},
+		Points: Points{{5, 10}, {5, 10}, {5, 0}},
+	}
+
+	if f, ok := p.DominantFeature(); !ok || f != Rotate {
+		t.Errorf("expected rotate, got (%v, %v)", f, ok)
+	}
+
+	p.Points = Points{{0, 0}}
+	if _, ok := p.DominantFeature(); ok {
+		t.Error("expected no dominant feature for a silent pattern")
+	}
+
+	p.Points = nil
+	if _, ok := p.DominantFeature(); ok {
+		t.Error("expected no dominant feature for an empty pattern")
+	}
+}
+
+func TestPointsEnvelope(t *testing.T) {
+	p := Points{{0, 20}, {20, 20}, {0, 0}, {0, 0}}
+
+	envelopes := p.Envelope(V1, 1, 0.5)
+	expect := [][]float64{
+		{0, 1, 0.5, 0.25},
+		{1, 1, 0.5, 0.25},
+	}
+	if diff := deep.Equal(envelopes, expect); diff != nil {
+		t.Fatalf("unexpected envelopes: %s", diff)
+	}
+
+	if envelopes := (Points{}).Envelope(V1, 1, 0.5); envelopes != nil {
+		t.Fatalf("expected nil envelopes, got %v", envelopes)
+	}
+}
+
+func TestPointsPeriod(t *testing.T) {
+	tests := []struct {
+		name       string
+		points     Points
+		period     int
+		confidence float64 // minimum
+	}{
+		{
+			name:       "periodic",
+			points:     Points{{0}, {10}, {20}, {10}, {0}, {10}, {20}, {10}, {0}, {10}, {20}, {10}},
+			period:     4,
+			confidence: 0.9,
+		},
+		{
+			name:       "dual motor",
+			points:     Points{{0, 20}, {20, 0}, {0, 20}, {20, 0}, {0, 20}, {20, 0}},
+			period:     0, // the mean strength is constant
+			confidence: 0,
+		},
+		{
+			name:   "constant",
+			points: Points{{5}, {5}, {5}, {5}, {5}},
+		},
+		{
+			name:   "ramp",
+			points: Points{{0}, {2}, {4}, {6}, {8}, {10}, {12}, {14}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			period, confidence := test.points.Period(V1)
+			if period != test.period {
+				t.Errorf("expected period %d, got %d", test.period, period)
+			}
+			if confidence < test.confidence || confidence > 1 {
+				t.Errorf("expected confidence within [%v, 1], got %v", test.confidence, confidence)
+			}
+			if test.period == 0 && confidence != 0 {
+				t.Errorf("expected no confidence, got %v", confidence)
+			}
+		})
+	}
+}
+
+func TestPointsDutyCycle(t *testing.T) {
+	p := Points{{20, 0}, {19, 5}, {10, 20}, {20, 0}}
+
+	cycles := p.DutyCycle(V1, 0.9)
+	if diff := deep.Equal(cycles, []float64{0.75, 0.25}); diff != nil {
+		t.Fatalf("unexpected duty cycles: %s", diff)
+	}
+
+	if cycles := (Points{}).DutyCycle(V1, 0.5); cycles != nil {
+		t.Fatalf("expected nil duty cycles, got %v", cycles)
+	}
+}
+
+func TestPatternIntensityLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern *Pattern
+		expect  float64
+	}{
+		{"empty", &Pattern{}, 0},
+		{
+			"v0",
+			&Pattern{
+				Header: Header{Version: V0, Interval: 100 * time.Millisecond},
+				Points: Points{{0}, {100}, {100}, {0}},
+			},
+			0.5,
+		},
+		{
+			"v1",
+			&Pattern{
+				Header: Header{Version: V1, Interval: 100 * time.Millisecond},
+				Points: Points{{20, 20}, {20, 0}},
+			},
+			1.5,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if load := test.pattern.IntensityLoad(); math.Abs(load-test.expect) > 1e-9 {
+				t.Errorf("expected %v, got %v", test.expect, load)
+			}
+		})
+	}
+}
+
+func TestPatternSimilarTo(t *testing.T) {
+	fast := &Pattern{
+		Header: Header{Version: V1, Interval: 100 * time.Millisecond},
+		Points: Points{{0}, {0}, {20}, {20}, {10}, {10}},
+	}
+
+	slow := &Pattern{
+		Header: Header{Version: V0, Interval: 200 * time.Millisecond},
+		Points: Points{{0}, {100}, {50}},
+	}
+
+	if !fast.SimilarTo(slow, 0.01) || !slow.SimilarTo(fast, 0.01) {
+		t.Error("expected patterns to be similar")
+	}
+
+	different := &Pattern{
+		Header: Header{Version: V0, Interval: 200 * time.Millisecond},
+		Points: Points{{0}, {100}, {0}},
+	}
+
+	if fast.SimilarTo(different, 0.01) {
+		t.Error("expected patterns to differ")
+	}
+
+	multi := &Pattern{
+		Header: Header{Version: V1, Interval: 100 * time.Millisecond},
+		Points: Points{{0, 0}},
+	}
+
+	if fast.SimilarTo(multi, 1) {
+		t.Error("expected patterns with differing motor counts to differ")
+	}
+
+	// Resampling a minute-long pattern to 1ns would take 60 billion points.
+	tiny := &Pattern{
+		Header: Header{Version: V1, Interval: time.Nanosecond},
+		Points: Points{{0}},
+	}
+	long := &Pattern{
+		Header: Header{Version: V1, Interval: time.Minute},
+		Points: Points{{0}},
+	}
+
+	if long.SimilarTo(tiny, 1) {
+		t.Error("expected patterns too long to resample to differ")
+	}
+}
+
+func TestZipByTime(t *testing.T) {
+	a := &Pattern{
+		Header: Header{Interval: 100 * time.Millisecond},
+		Points: Points{{1}, {2}, {3}},
+	}
+	b := &Pattern{
+		Header: Header{Interval: 150 * time.Millisecond},
+		Points: Points{{4, 5}},
+	}
+
+	zipped, err := ZipByTime(a, b)
+	if err != nil {
+		t.Fatal("cannot zip:", err)
+	}
+
+	expect := []TimedPoints{
+		{0, Points{{1}, {4, 5}}},
+		{50 * time.Millisecond, Points{{1}, {4, 5}}},
+		{100 * time.Millisecond, Points{{2}, {4, 5}}},
+		{150 * time.Millisecond, Points{{2}, nil}},
+		{200 * time.Millisecond, Points{{3}, nil}},
+		{250 * time.Millisecond, Points{{3}, nil}},
+	}
+
+	if diff := deep.Equal(zipped, expect); diff != nil {
+		t.Fatalf("unexpected zipped points: %s", diff)
+	}
+
+	if _, err := ZipByTime(a, &Pattern{}); err == nil {
+		t.Error("expected error for pattern without interval")
+	}
+
+	coprime := &Pattern{
+		Header: Header{Interval: 100*time.Millisecond + 1},
+		Points: Points{{1}},
+	}
+	if _, err := ZipByTime(a, coprime); err == nil {
+		t.Error("expected error for co-prime intervals")
+	}
+
+	long := &Pattern{
+		Header: Header{Interval: time.Millisecond},
+		Points: make(Points, maxResampleSteps+1),
+	}
+	if _, err := ZipByTime(long); err == nil {
+		t.Error("expected error for too many steps")
+	}
+}
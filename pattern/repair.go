@@ -0,0 +1,79 @@
+package pattern
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// readAllV1PointsRepair is like ReadAllV1Points, except tuples are allowed to
+// have differing strides. Tuples that don't have the most common stride are
+// padded with zeros or truncated, and their indices are returned.
+func (r *Reader) readAllV1PointsRepair() (Points, []int, error) {
+	var points Points
+	var b []byte
+	var err error
+
+	for err == nil {
+		b, err = r.readSlice(';')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, nil, fmt.Errorf("cannot read: %w", err)
+		}
+
+		b = bytes.TrimSuffix(b, []byte(";"))
+		b = bytes.TrimSpace(b)
+
+		if len(b) == 0 {
+			continue
+		}
+
+		point := make(Point, 0, bytes.Count(b, []byte(","))+1)
+
+		pr := sepReader{b: b, s: ','}
+		for v := pr.next(); v != nil; v = pr.next() {
+			p, err := strconv.ParseUint(string(v), 10, 8)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid point: %w", err)
+			}
+			point = append(point, Strength(p))
+		}
+
+		points = append(points, point)
+	}
+
+	stride := modalStride(points)
+
+	var repaired []int
+
+	for i, point := range points {
+		if len(point) == stride {
+			continue
+		}
+
+		fixed := make(Point, stride)
+		copy(fixed, point)
+
+		points[i] = fixed
+		repaired = append(repaired, i)
+	}
+
+	return points, repaired, nil
+}
+
+// modalStride returns the most common stride in points. Ties are broken by
+// whichever stride reaches the count first.
+func modalStride(points Points) int {
+	counts := make(map[int]int)
+	var stride int
+
+	for _, point := range points {
+		counts[len(point)]++
+		if counts[len(point)] > counts[stride] {
+			stride = len(point)
+		}
+	}
+
+	return stride
+}
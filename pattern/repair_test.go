@@ -0,0 +1,29 @@
+package pattern
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestParseRepair(t *testing.T) {
+	const input = "V:1;F:v1,v2;#0,1;2;3,4;5,6,7;8,9;"
+
+	if _, err := ParseString(input); err == nil {
+		t.Fatal("expected strict Parse to fail")
+	}
+
+	p, repaired, err := ParseRepair(strings.NewReader(input))
+	if err != nil {
+		t.Fatal("cannot parse with repair:", err)
+	}
+
+	if diff := deep.Equal(p.Points, Points{{0, 1}, {2, 0}, {3, 4}, {5, 6}, {8, 9}}); diff != nil {
+		t.Errorf("unexpected points: %s", diff)
+	}
+
+	if diff := deep.Equal(repaired, []int{1, 3}); diff != nil {
+		t.Errorf("unexpected repaired indices: %s", diff)
+	}
+}
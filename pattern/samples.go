@@ -0,0 +1,37 @@
+package pattern
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed samples
+var samples embed.FS
+
+// SamplePatterns returns a few small built-in patterns of both versions and of
+// varying motor counts. They're meant for tests and examples that shouldn't
+// need network access. A new copy is parsed on every call, so the returned
+// patterns may be modified freely.
+func SamplePatterns() []*Pattern {
+	entries, err := fs.ReadDir(samples, "samples")
+	if err != nil {
+		panic("cannot read embedded samples: " + err.Error())
+	}
+
+	patterns := make([]*Pattern, len(entries))
+	for i, entry := range entries {
+		b, err := samples.ReadFile("samples/" + entry.Name())
+		if err != nil {
+			panic("cannot read embedded sample: " + err.Error())
+		}
+
+		p, err := ParseBytes(b)
+		if err != nil {
+			panic("cannot parse embedded sample " + entry.Name() + ": " + err.Error())
+		}
+
+		patterns[i] = p
+	}
+
+	return patterns
+}
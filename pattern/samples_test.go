@@ -0,0 +1,33 @@
+package pattern
+
+import "testing"
+
+func TestSamplePatterns(t *testing.T) {
+	patterns := SamplePatterns()
+	if len(patterns) == 0 {
+		t.Fatal("expected sample patterns")
+	}
+
+	versions := make(map[Version]bool)
+	strides := make(map[int]bool)
+
+	for _, p := range patterns {
+		if len(p.Points) == 0 {
+			t.Errorf("sample %q has no points", p.Type)
+		}
+		versions[p.Version] = true
+		strides[p.Points.Stride()] = true
+	}
+
+	if !versions[V0] || !versions[V1] {
+		t.Errorf("expected samples of both versions, got %v", versions)
+	}
+	if len(strides) < 2 {
+		t.Errorf("expected samples of varying motor counts, got %v", strides)
+	}
+
+	patterns[0].Points[0][0] = 99
+	if SamplePatterns()[0].Points[0][0] == 99 {
+		t.Error("modifying a sample modified the embedded samples")
+	}
+}
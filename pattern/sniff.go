@@ -0,0 +1,83 @@
+package pattern
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxWrapping is the maximum number of layers of encoding that ParseAny will
+// unwrap, e.g. base64 over gzip is 2 layers.
+const maxWrapping = 3
+
+// ParseAny is like Parse, except r may also be gzip-compressed or
+// base64-encoded, which is detected from its content. Detection is
+// conservative: anything that could be a raw pattern file is parsed as one.
+func ParseAny(r io.Reader) (*Pattern, error) {
+	buf := bufio.NewReader(r)
+
+	for i := 0; i < maxWrapping; i++ {
+		b, err := buf.Peek(buf.Size())
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, fmt.Errorf("cannot peek: %w", err)
+		}
+
+		switch {
+		case isGzip(b):
+			z, err := gzip.NewReader(buf)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read gzip: %w", err)
+			}
+			defer z.Close()
+
+			buf = bufio.NewReader(z)
+		case isBase64(b):
+			buf = bufio.NewReader(base64.NewDecoder(base64.StdEncoding, buf))
+		default:
+			return Parse(buf)
+		}
+	}
+
+	return Parse(buf)
+}
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+func isGzip(b []byte) bool {
+	return bytes.HasPrefix(b, gzipMagic)
+}
+
+var base64Chars = func() (chars [256]bool) {
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/="
+	for i := 0; i < len(alphabet); i++ {
+		chars[alphabet[i]] = true
+	}
+	return
+}()
+
+// isBase64 returns true if b only contains base64 characters. Raw pattern files
+// are made of digits and separators (and "V:" for version 1), so a raw version
+// 0 pattern file with only a single point can also pass as base64; b is
+// therefore only considered base64 if it has at least one non-digit character.
+func isBase64(b []byte) bool {
+	var nonDigit bool
+
+	for _, c := range b {
+		switch {
+		case base64Chars[c]:
+			if c < '0' || c > '9' {
+				nonDigit = true
+			}
+		case c == '\n', c == '\r':
+			// base64.NewDecoder skips newlines.
+		default:
+			return false
+		}
+	}
+
+	return nonDigit
+}
@@ -0,0 +1,63 @@
+package pattern
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"os"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestParseAny(t *testing.T) {
+	for _, name := range []string{"testdata/edge", "testdata/v0"} {
+		raw, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("cannot read %s: %v", name, err)
+		}
+
+		expect, err := Parse(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatalf("cannot parse %s: %v", name, err)
+		}
+
+		var gz bytes.Buffer
+		w := gzip.NewWriter(&gz)
+		w.Write(raw)
+		w.Close()
+
+		inputs := map[string][]byte{
+			"raw":         raw,
+			"gzip":        gz.Bytes(),
+			"base64":      []byte(base64.StdEncoding.EncodeToString(raw)),
+			"base64+gzip": []byte(base64.StdEncoding.EncodeToString(gz.Bytes())),
+		}
+
+		for wrapping, input := range inputs {
+			t.Run(name+"/"+wrapping, func(t *testing.T) {
+				p, err := ParseAny(bytes.NewReader(input))
+				if err != nil {
+					t.Fatal("cannot parse:", err)
+				}
+
+				if diff := deep.Equal(p, expect); diff != nil {
+					t.Fatalf("unexpected pattern: %s", diff)
+				}
+			})
+		}
+	}
+}
+
+func TestParseAnySinglePoint(t *testing.T) {
+	// "12" is valid base64, but it should still be parsed as a raw v0 pattern
+	// file.
+	p, err := ParseAny(bytes.NewReader([]byte("12")))
+	if err != nil {
+		t.Fatal("cannot parse:", err)
+	}
+
+	if diff := deep.Equal(p.Points, Points{{12}}); diff != nil {
+		t.Fatalf("unexpected points: %s", diff)
+	}
+}
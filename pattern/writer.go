@@ -0,0 +1,301 @@
+package pattern
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ErrWriterClosed is returned when writing to a Writer after Close.
+var ErrWriterClosed = errors.New("writer closed")
+
+// Writer provides a Lovense pattern writer. It writes the same format that
+// Reader reads. Writes are buffered, so Flush or Close must be called once
+// everything is written. Close also checks that the output can be read back.
+type Writer struct {
+	w       *bufio.Writer
+	out     io.Writer
+	buf     []byte
+	version Version
+
+	typ      string                // header's type, for mismatch errors
+	features int                   // number of features in the header
+	mismatch *FeatureMismatchError // first point not matching the features
+
+	header bool // wrote a version 1 header
+	points bool // wrote at least one point
+	mixed  bool // wrote points of a version other than the header's
+	closed bool
+}
+
+// NewWriter creates a new writer that writes into the given io.Writer.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w:   bufio.NewWriter(w),
+		out: w,
+	}
+}
+
+// WriteHeader writes the header. Version 0 pattern files don't have a header,
+// so nothing is written for them. An unset Interval is written as
+// DefaultInterval, while one outside [MinInterval, MaxInterval] is rejected,
+// since Reader wouldn't be able to read it back.
+func (w *Writer) WriteHeader(h Header) error {
+	if w.closed {
+		return ErrWriterClosed
+	}
+
+	switch h.Version {
+	case V0:
+		w.version = V0
+		return nil
+	case V1:
+		// ok
+	default:
+		return fmt.Errorf("%w %d", ErrUnknownVersion, h.Version)
+	}
+
+	interval := h.EffectiveInterval()
+	if interval < MinInterval || interval > MaxInterval {
+		return fmt.Errorf("interval %v out of range [%v, %v]", interval, MinInterval, MaxInterval)
+	}
+
+	w.version = h.Version
+	w.typ = h.Type
+	w.features = len(h.Features)
+
+	b := w.buf[:0]
+	b = append(b, "V:"...)
+	b = strconv.AppendInt(b, int64(h.Version), 10)
+	b = append(b, ";T:"...)
+	b = append(b, h.Type...)
+	b = append(b, ";F:"...)
+	for i, f := range h.Features {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, f...)
+	}
+	b = append(b, ";S:"...)
+	b = strconv.AppendInt(b, int64(interval/time.Millisecond), 10)
+	b = append(b, ";M:"...)
+	b = append(b, h.MD5Sum...)
+
+	// Sort the extra keys so that the output is deterministic.
+	keys := make([]string, 0, len(h.Extra))
+	for k := range h.Extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		b = append(b, ';')
+		b = append(b, k...)
+		b = append(b, ':')
+		b = append(b, h.Extra[k]...)
+	}
+
+	b = append(b, ";#\n"...)
+	w.buf = b
+	w.header = true
+
+	_, err := w.w.Write(b)
+	return err
+}
+
+// WritePoints writes all points in the format of the given version. Each point
+// in a version 0 pattern file must only have a single motor.
+func (w *Writer) WritePoints(v Version, points Points) error {
+	if w.closed {
+		return ErrWriterClosed
+	}
+
+	if len(points) > 0 {
+		w.points = true
+		w.mixed = w.mixed || v != w.version
+	}
+
+	for _, point := range points {
+		if err := w.writePoint(v, point); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WritePoint writes a single point in the format of the version given to the
+// last WriteHeader call, or version 0 if it was never called. Each point is
+// followed by its separator, so points can be written one by one as they're
+// produced.
+func (w *Writer) WritePoint(point Point) error {
+	if w.closed {
+		return ErrWriterClosed
+	}
+
+	w.points = true
+	return w.writePoint(w.version, point)
+}
+
+// writePoint encodes the point into the reused scratch buffer before copying
+// it into the bufio.Writer, so writing doesn't allocate.
+func (w *Writer) writePoint(v Version, point Point) error {
+	// Headers without features are read back with placeholders matching the
+	// points, so only check the width if there are features to check against.
+	if w.mismatch == nil && w.header && v == V1 && w.features > 0 && len(point) != w.features {
+		w.mismatch = &FeatureMismatchError{
+			Type:     w.typ,
+			Declared: w.features,
+			Actual:   len(point),
+		}
+	}
+
+	b, err := appendPoint(w.buf[:0], v, point)
+	if err != nil {
+		return err
+	}
+
+	w.buf = b
+
+	_, err = w.w.Write(b)
+	return err
+}
+
+// Flush writes any buffered data into the underlying io.Writer.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// Close flushes the Writer and finishes writing the pattern file. It errors out
+// if what was written can't be read back by Reader: either nothing was written
+// at all, points were written in a version other than the header's, such as
+// version 1 points without a header, or points didn't have as many motors as
+// the header has features. If the underlying io.Writer has a Flush
+// method, such as *bufio.Writer, it is flushed too. Writing after Close returns
+// ErrWriterClosed.
+//
+// Close doesn't close the underlying io.Writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if err := w.w.Flush(); err != nil {
+		return fmt.Errorf("cannot flush: %w", err)
+	}
+
+	if f, ok := w.out.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			return fmt.Errorf("cannot flush: %w", err)
+		}
+	}
+
+	switch {
+	case !w.header && !w.points:
+		return ErrEmptyPattern
+	case w.mixed:
+		return fmt.Errorf("points were written in a version other than %v", w.version)
+	case w.mismatch != nil:
+		return w.mismatch
+	}
+
+	return nil
+}
+
+func appendPoint(b []byte, v Version, point Point) ([]byte, error) {
+	switch v {
+	case V0:
+		if len(point) != 1 {
+			return b, fmt.Errorf("v0 point has %d motors, expected 1", len(point))
+		}
+		b = strconv.AppendUint(b, uint64(point[0]), 10)
+		b = append(b, ',')
+	case V1:
+		b = point.appendWire(b)
+		b = append(b, ';')
+	default:
+		return b, fmt.Errorf("%w %d", ErrUnknownVersion, v)
+	}
+
+	return b, nil
+}
+
+// WriteTo writes the pattern into w in the same format that Lovense produces.
+// It implements io.WriterTo.
+func (p *Pattern) WriteTo(w io.Writer) (int64, error) {
+	cw := countWriter{w: w}
+	pw := NewWriter(&cw)
+
+	if err := pw.WriteHeader(p.Header); err != nil {
+		return cw.n, fmt.Errorf("cannot write header: %w", err)
+	}
+
+	if err := pw.WritePoints(p.Version, p.Points); err != nil {
+		return cw.n, fmt.Errorf("cannot write points: %w", err)
+	}
+
+	if err := pw.Flush(); err != nil {
+		return cw.n, err
+	}
+
+	return cw.n, nil
+}
+
+// WriteRawTo writes Raw into w unchanged. If Raw is nil, it falls back to
+// WriteTo. Since Raw isn't updated when the pattern is modified, WriteTo should
+// be used instead for modified patterns.
+func (p *Pattern) WriteRawTo(w io.Writer) (int64, error) {
+	if p.Raw == nil {
+		return p.WriteTo(w)
+	}
+
+	n, err := w.Write(p.Raw)
+	return int64(n), err
+}
+
+// WritePlot writes the given motor's strengths as lines of "elapsed strength",
+// where elapsed is in seconds and strength is scaled to [0.0, 1.0]. This is the
+// data format that gnuplot's plot command reads.
+func (p *Pattern) WritePlot(w io.Writer, motor int) error {
+	times, values, err := p.TimeSeries(motor)
+	if err != nil {
+		return err
+	}
+
+	var b []byte
+	for i := range times {
+		b = strconv.AppendFloat(b, times[i].Seconds(), 'f', -1, 64)
+		b = append(b, ' ')
+		b = strconv.AppendFloat(b, values[i], 'f', -1, 64)
+		b = append(b, '\n')
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+// Bytes returns the pattern serialized the same way as WriteTo.
+func (p *Pattern) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := p.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,352 @@
+package pattern
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestPatternBytes(t *testing.T) {
+	p := &Pattern{
+		Header: Header{
+			Version:  V1,
+			Type:     "Edge",
+			Features: []Feature{Vibrate1, Vibrate2},
+			Interval: 100 * time.Millisecond,
+			MD5Sum:   "deadbeef",
+		},
+		Points: Points{{0, 1}, {20, 0}, {5, 5}},
+	}
+
+	b, err := p.Bytes()
+	if err != nil {
+		t.Fatal("cannot serialize pattern:", err)
+	}
+
+	const expect = "V:1;T:Edge;F:v1,v2;S:100;M:deadbeef;#\n0,1;20,0;5,5;"
+	if string(b) != expect {
+		t.Fatalf("expected %q, got %q", expect, b)
+	}
+}
+
+func TestPatternBytesExtra(t *testing.T) {
+	p, err := ParseString("V:1;T:Edge;F:v;S:100;M:;X:1;C:red;#\n0;1;")
+	if err != nil {
+		t.Fatal("cannot parse pattern:", err)
+	}
+
+	b, err := p.Bytes()
+	if err != nil {
+		t.Fatal("cannot serialize pattern:", err)
+	}
+
+	const expect = "V:1;T:Edge;F:v;S:100;M:;C:red;X:1;#\n0;1;"
+	if string(b) != expect {
+		t.Fatalf("expected %q, got %q", expect, b)
+	}
+}
+
+func TestPatternWriteTo(t *testing.T) {
+	for _, name := range []string{"testdata/edge", "testdata/v0"} {
+		t.Run(name, func(t *testing.T) {
+			p, err := Parse(openFile(t, name))
+			if err != nil {
+				t.Fatalf("cannot parse %s: %v", name, err)
+			}
+
+			var buf bytes.Buffer
+
+			n, err := p.WriteTo(&buf)
+			if err != nil {
+				t.Fatal("cannot write pattern:", err)
+			}
+			if n != int64(buf.Len()) {
+				t.Errorf("WriteTo returned %d, but %d bytes were written", n, buf.Len())
+			}
+
+			roundTrip, err := Parse(&buf)
+			if err != nil {
+				t.Fatal("cannot parse written pattern:", err)
+			}
+
+			if diff := deep.Equal(p, roundTrip); diff != nil {
+				t.Fatalf("round-trip mismatch: %s", diff)
+			}
+		})
+	}
+}
+
+func TestPatternWriteRawTo(t *testing.T) {
+	const input = "V:1;T:Edge;F:v1,v2;S:100;M:;#\n0,1; 20,0;;\n"
+
+	p, err := ParseRaw(strings.NewReader(input))
+	if err != nil {
+		t.Fatal("cannot parse pattern:", err)
+	}
+
+	if diff := deep.Equal(p.Points, Points{{0, 1}, {20, 0}}); diff != nil {
+		t.Fatalf("unexpected points: %s", diff)
+	}
+
+	var buf bytes.Buffer
+	if _, err := p.WriteRawTo(&buf); err != nil {
+		t.Fatal("cannot write raw pattern:", err)
+	}
+
+	if buf.String() != input {
+		t.Fatalf("expected %q, got %q", input, buf.String())
+	}
+
+	p.Raw = nil
+	buf.Reset()
+
+	if _, err := p.WriteRawTo(&buf); err != nil {
+		t.Fatal("cannot write pattern:", err)
+	}
+
+	const expect = "V:1;T:Edge;F:v1,v2;S:100;M:;#\n0,1;20,0;"
+	if buf.String() != expect {
+		t.Fatalf("expected fallback %q, got %q", expect, buf.String())
+	}
+}
+
+func TestPatternWritePlot(t *testing.T) {
+	p := &Pattern{
+		Header: Header{Version: V1, Interval: 100 * time.Millisecond},
+		Points: Points{{0, 20}, {10, 5}, {20, 0}},
+	}
+
+	var buf bytes.Buffer
+	if err := p.WritePlot(&buf, 1); err != nil {
+		t.Fatal("cannot write plot:", err)
+	}
+
+	const expect = "0 1\n0.1 0.25\n0.2 0\n"
+	if buf.String() != expect {
+		t.Fatalf("expected %q, got %q", expect, buf.String())
+	}
+
+	if err := p.WritePlot(&buf, 2); err == nil {
+		t.Fatal("expected error for out of range motor")
+	}
+}
+
+func TestWriterWritePoint(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	h := Header{
+		Version:  V1,
+		Features: []Feature{Vibrate1, Vibrate2},
+		Interval: 100 * time.Millisecond,
+	}
+
+	if err := w.WriteHeader(h); err != nil {
+		t.Fatal("cannot write header:", err)
+	}
+
+	points := Points{{0, 1}, {20, 0}, {5, 5}}
+	for _, point := range points {
+		if err := w.WritePoint(point); err != nil {
+			t.Fatal("cannot write point:", err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal("cannot close writer:", err)
+	}
+
+	p, err := Parse(&buf)
+	if err != nil {
+		t.Fatal("cannot parse written pattern:", err)
+	}
+
+	if diff := deep.Equal(p.Points, points); diff != nil {
+		t.Fatalf("unexpected points: %s", diff)
+	}
+}
+
+func TestWriterClose(t *testing.T) {
+	tests := []struct {
+		name   string
+		write  func(w *Writer) error
+		expect *Pattern // nil if Close should error
+	}{
+		{
+			name: "v1",
+			write: func(w *Writer) error {
+				if err := w.WriteHeader(Header{Version: V1, Features: []Feature{Vibrate}, Interval: time.Second}); err != nil {
+					return err
+				}
+				return w.WritePoints(V1, Points{{1}, {2}})
+			},
+			expect: &Pattern{
+				Header: Header{Version: V1, Features: []Feature{Vibrate}, Interval: time.Second},
+				Points: Points{{1}, {2}},
+			},
+		},
+		{
+			name: "v1 header only",
+			write: func(w *Writer) error {
+				return w.WriteHeader(Header{Version: V1, Features: []Feature{Vibrate}, Interval: time.Second})
+			},
+			expect: &Pattern{
+				Header: Header{Version: V1, Features: []Feature{Vibrate}, Interval: time.Second},
+				Points: Points{},
+			},
+		},
+		{
+			name: "v0",
+			write: func(w *Writer) error {
+				return w.WritePoints(V0, Points{{50}, {100}})
+			},
+			expect: &Pattern{
+				Header: Header{Version: V0, Features: []Feature{Vibrate}, Interval: DefaultInterval},
+				Points: Points{{50}, {100}},
+			},
+		},
+		{
+			name:  "empty",
+			write: func(w *Writer) error { return nil },
+		},
+		{
+			name: "v1 without header",
+			write: func(w *Writer) error {
+				return w.WritePoints(V1, Points{{1}, {2}})
+			},
+		},
+		{
+			name: "v1 zero interval",
+			write: func(w *Writer) error {
+				if err := w.WriteHeader(Header{Version: V1, Features: []Feature{Vibrate}}); err != nil {
+					return err
+				}
+				return w.WritePoints(V1, Points{{1}, {2}})
+			},
+			expect: &Pattern{
+				Header: Header{Version: V1, Features: []Feature{Vibrate}, Interval: DefaultInterval},
+				Points: Points{{1}, {2}},
+			},
+		},
+		{
+			name: "v1 width mismatch",
+			write: func(w *Writer) error {
+				if err := w.WriteHeader(Header{Version: V1, Features: []Feature{Vibrate1, Vibrate2}, Interval: time.Second}); err != nil {
+					return err
+				}
+				return w.WritePoints(V1, Points{{1, 2}, {3}})
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf)
+
+			if err := test.write(w); err != nil {
+				t.Fatal("cannot write:", err)
+			}
+
+			err := w.Close()
+			if test.expect == nil {
+				if err == nil {
+					t.Fatalf("expected Close to error, wrote %q", buf.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("cannot close writer:", err)
+			}
+
+			p, err := Parse(&buf)
+			if err != nil {
+				t.Fatal("cannot parse written pattern:", err)
+			}
+
+			if diff := deep.Equal(p, test.expect); diff != nil {
+				t.Fatalf("round-trip mismatch: %s", diff)
+			}
+
+			if err := w.WritePoint(Point{1}); !errors.Is(err, ErrWriterClosed) {
+				t.Fatalf("expected ErrWriterClosed after Close, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWriterWriteHeaderInterval(t *testing.T) {
+	for _, interval := range []time.Duration{time.Microsecond, 2 * MaxInterval} {
+		w := NewWriter(io.Discard)
+		err := w.WriteHeader(Header{Version: V1, Features: []Feature{Vibrate}, Interval: interval})
+		if err == nil {
+			t.Errorf("expected error writing header with interval %v", interval)
+		}
+	}
+}
+
+func TestWriterCloseMismatch(t *testing.T) {
+	w := NewWriter(io.Discard)
+	if err := w.WriteHeader(Header{Version: V1, Features: []Feature{Vibrate1, Vibrate2}, Interval: time.Second}); err != nil {
+		t.Fatal("cannot write header:", err)
+	}
+	if err := w.WritePoint(Point{1}); err != nil {
+		t.Fatal("cannot write point:", err)
+	}
+
+	if err := w.Close(); !errors.Is(err, ErrFeatureMismatch) {
+		t.Fatalf("expected ErrFeatureMismatch, got %v", err)
+	}
+}
+
+func TestWriterCloseFlush(t *testing.T) {
+	var buf bytes.Buffer
+	bw := bufio.NewWriter(&buf)
+
+	w := NewWriter(bw)
+	if err := w.WritePoints(V0, Points{{5}}); err != nil {
+		t.Fatal("cannot write points:", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal("cannot close writer:", err)
+	}
+
+	if buf.String() != "5," {
+		t.Fatalf("expected flushed output %q, got %q", "5,", buf.String())
+	}
+}
+
+func BenchmarkWriterWritePoints(b *testing.B) {
+	points := make(Points, 100000)
+	for i := range points {
+		points[i] = Point{Strength(i % 21), Strength(20 - i%21)}
+	}
+
+	h := Header{
+		Version:  V1,
+		Features: []Feature{Vibrate1, Vibrate2},
+		Interval: 100 * time.Millisecond,
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		w := NewWriter(io.Discard)
+		if err := w.WriteHeader(h); err != nil {
+			b.Fatal("cannot write header:", err)
+		}
+		if err := w.WritePoints(V1, points); err != nil {
+			b.Fatal("cannot write points:", err)
+		}
+		if err := w.Close(); err != nil {
+			b.Fatal("cannot close writer:", err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package pattern
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// MaxZipEntrySize is the maximum size of a decompressed file that ParseZip
+// reads. Larger files are skipped, so that a malicious archive can't exhaust
+// memory.
+const MaxZipEntrySize = 16 << 20 // 16 MiB
+
+// ParseZip parses every pattern file in the zip archive into a map keyed by
+// the files' names within the archive. Files may also be compressed or encoded
+// like in ParseAny. Directories, files that can't be decompressed, files larger
+// than MaxZipEntrySize and files that aren't valid pattern files are skipped;
+// only an error from reading the archive's directory is returned.
+func ParseZip(r io.ReaderAt, size int64) (map[string]*Pattern, error) {
+	z, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read zip: %w", err)
+	}
+
+	patterns := make(map[string]*Pattern, len(z.File))
+
+	for _, f := range z.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		b, err := readZipFile(f)
+		if err != nil {
+			continue
+		}
+
+		p, err := ParseAny(bytes.NewReader(b))
+		if err != nil {
+			continue
+		}
+
+		patterns[f.Name] = p
+	}
+
+	return patterns, nil
+}
+
+// readZipFile reads all of f, up to MaxZipEntrySize. The whole file is read
+// before parsing it, so that a bad checksum at the end is caught.
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	b, err := io.ReadAll(io.LimitReader(rc, MaxZipEntrySize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) > MaxZipEntrySize {
+		return nil, fmt.Errorf("file is larger than %d bytes", MaxZipEntrySize)
+	}
+
+	return b, nil
+}
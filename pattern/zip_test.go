@@ -0,0 +1,88 @@
+package pattern
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestParseZip(t *testing.T) {
+	var gz bytes.Buffer
+	gzw := gzip.NewWriter(&gz)
+	gzw.Write([]byte("0,50,100,"))
+	gzw.Close()
+
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"packs/", nil},
+		{"packs/edge", []byte("V:1;T:Edge;F:v1,v2;S:100;M:;#\n0,1;20,0;")},
+		{"packs/v0.gz", gz.Bytes()},
+		{"README.txt", []byte("Thanks for downloading!")},
+		{"empty", nil},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal("cannot create zip file:", err)
+		}
+		w.Write(f.data)
+	}
+
+	// A compression method that only the writer knows can't be opened.
+	zw.RegisterCompressor(99, func(w io.Writer) (io.WriteCloser, error) {
+		return nopWriteCloser{w}, nil
+	})
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "weird", Method: 99})
+	if err != nil {
+		t.Fatal("cannot create zip file:", err)
+	}
+	w.Write([]byte("0,1,"))
+
+	// Compresses well, but decompresses to more than MaxZipEntrySize.
+	w, err = zw.Create("bomb")
+	if err != nil {
+		t.Fatal("cannot create zip file:", err)
+	}
+	w.Write(bytes.Repeat([]byte("0,"), MaxZipEntrySize/2+1))
+	if err := zw.Close(); err != nil {
+		t.Fatal("cannot close zip:", err)
+	}
+
+	patterns, err := ParseZip(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal("cannot parse zip:", err)
+	}
+
+	points := map[string]Points{}
+	for name, p := range patterns {
+		points[name] = p.Points
+	}
+
+	expect := map[string]Points{
+		"packs/edge":  {{0, 1}, {20, 0}},
+		"packs/v0.gz": {{0}, {50}, {100}},
+	}
+	if diff := deep.Equal(points, expect); diff != nil {
+		t.Fatalf("unexpected patterns: %s", diff)
+	}
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestParseZipInvalid(t *testing.T) {
+	b := []byte("not a zip")
+	if _, err := ParseZip(bytes.NewReader(b), int64(len(b))); err == nil {
+		t.Fatal("expected error parsing invalid zip")
+	}
+}